@@ -0,0 +1,145 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// backfillTable records the files of an input directory that --backfill has
+// loaded, so that a backfill that was interrupted skips them when it is run
+// again.
+var backfillTable = table{
+	Name: "etl_backfill_files",
+	Columns: []column{
+		{Name: "file_name", Type: "VARCHAR(255)", Unique: true},
+		{Name: "file_checksum", Type: "VARCHAR(80)"},
+		{Name: "row_count", Type: "INT"},
+		{Name: "loaded_at", Type: "DATETIME"},
+	},
+}
+
+// backfill loads the files of an input directory into the database one after
+// the other, in the order of their names, each in a load of its own that is
+// recorded in load_metadata. A file is recorded in backfillTable once its load
+// is committed and a later backfill skips it. The kickstarter_ids that are
+// already loaded are skipped like with --resume, which also loads the rest of
+// a file whose load was interrupted, so that an interrupted backfill that is
+// run again ends with the same rows, and ids, as one that was not
+// interrupted.
+type backfill struct {
+	db            *sql.DB
+	dialect       dialect
+	files         []string
+	in            inputOptions
+	timeout       time.Duration
+	sourceVersion string
+	// The transformer and the loader are shared by the files, so that a
+	// kickstarter_id repeated in a later file is dropped like one repeated
+	// in the same file.
+	transformer *KickstartTransformer
+	loader      *SQLLoader
+	report      *loadReport
+	warns       *warnings
+	errs        *rowErrors
+	stop        *shutdown
+}
+
+// run runs the backfill and returns how long each stage took in total.
+func (b *backfill) run(ctx context.Context) (stageTimes, error) {
+	var times stageTimes
+	if err := createTables(ctx, b.db, b.dialect, b.loader.tables); err != nil {
+		return times, err
+	}
+	done, err := readBackfilledFiles(ctx, b.db, b.dialect)
+	if err != nil {
+		return times, fmt.Errorf("reading backfilled files: %v", err)
+	}
+	loaded, err := loadedKickstarterIDs(ctx, b.db, b.dialect)
+	if err != nil {
+		return times, fmt.Errorf("reading loaded rows: %v", err)
+	}
+	b.transformer.filter.loaded = loaded
+	slog.Info("Backfilling", "files", len(b.files), "backfilled_files", len(done), "loaded_rows", len(loaded))
+
+	for _, path := range b.files {
+		if b.stop.stopRequested() {
+			return times, ErrInterrupted
+		}
+		meta, err := newLoadMetadata(path, "", b.sourceVersion)
+		if err != nil {
+			return times, fmt.Errorf("describing load of %s: %v", path, err)
+		}
+		sum := meta.FileChecksum.String
+		if s, ok := done[meta.FileName]; ok {
+			if s != sum {
+				return times, fmt.Errorf("%s changed since it was backfilled", meta.FileName)
+			}
+			slog.Info("Skipping backfilled file", "file", meta.FileName)
+			continue
+		}
+
+		n, t, err := b.load(ctx, path, &meta)
+		times.Extract += t.Extract
+		times.Transform += t.Transform
+		times.Load += t.Load
+		if err != nil {
+			return times, err
+		}
+		if err := markBackfilled(ctx, b.db, b.dialect, meta.FileName, sum, n); err != nil {
+			return times, fmt.Errorf("recording backfilled file %s: %v", meta.FileName, err)
+		}
+		slog.Info("Backfilled file", "file", meta.FileName, "rows", n)
+	}
+	return times, nil
+}
+
+// load loads the file at path described by meta and returns the number of
+// kickstarts that were loaded.
+func (b *backfill) load(ctx context.Context, path string, meta *loadMetadata) (int, stageTimes, error) {
+	r, name, err := openInput(ctx, path, b.timeout)
+	if err != nil {
+		return 0, stageTimes{}, err
+	}
+	defer r.Close()
+	slog.Info("Extracting data", "input", name)
+	errs := &rowErrors{skip: b.errs.skip, warns: b.warns}
+	defer b.errs.addFile(filepath.Base(path), errs)
+	b.loader.meta = meta
+	n, times, err := runStages(ctx, r, newExtractor(b.in, b.warns, errs), b.transformer, reportingLoader{b.loader, b.report})
+	if err != nil && err != ErrInterrupted {
+		return n, times, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	return n, times, err
+}
+
+// readBackfilledFiles returns the checksums of the files in backfillTable by
+// their names.
+func readBackfilledFiles(ctx context.Context, db *sql.DB, d dialect) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT file_name, file_checksum FROM "+d.table(backfillTable.Name))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files := make(map[string]string)
+	for rows.Next() {
+		var name, sum string
+		if err := rows.Scan(&name, &sum); err != nil {
+			return nil, err
+		}
+		files[name] = sum
+	}
+	return files, rows.Err()
+}
+
+// markBackfilled records in backfillTable that the file named name with
+// checksum sum was loaded. It is recorded after the load of the file is
+// committed, so a backfill that stops in between loads the file again, which
+// skips all its rows as they are already loaded.
+func markBackfilled(ctx context.Context, db *sql.DB, d dialect, name, sum string, rows int) error {
+	_, err := db.ExecContext(ctx, d.insert(backfillTable.Name, 1, "file_name", "file_checksum", "row_count", "loaded_at"), name, sum, rows, d.timeArg(time.Now().UTC().Round(0)))
+	return err
+}
@@ -0,0 +1,190 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testBackfillDir writes a directory of three monthly files of three rows each
+// and returns its path. A kickstarter_id of the first file is repeated in the
+// last one.
+func testBackfillDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	countries := []string{"US", "GB", "DE", "FR", "IT", "ES", "NL", "SE", "NO"}
+	for f := 0; f < 3; f++ {
+		var rows []string
+		for i := 0; i < 3; i++ {
+			n := f*3 + i
+			id := fmt.Sprint(n + 1)
+			if n == 8 {
+				id = "1"
+			}
+			rows = append(rows, testRow(map[string]string{
+				"ID":       id,
+				"name":     fmt.Sprintf("Project %d", n),
+				"country":  countries[n],
+				"category": fmt.Sprintf("Category %d", n%4),
+			}))
+		}
+		path := filepath.Join(dir, fmt.Sprintf("2018-%02d.csv", f+1))
+		if err := os.WriteFile(path, []byte(testCSV(rows...)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// dumpTables returns every row of the tables of the star schema in db.
+func dumpTables(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var b strings.Builder
+	for _, table := range schemaTables {
+		rows, err := db.Query("SELECT * FROM " + table.Name + " ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(&b, table.Name)
+		for rows.Next() {
+			values := make([]interface{}, len(cols))
+			dest := make([]interface{}, len(cols))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprintln(&b, values...)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+	}
+	return b.String()
+}
+
+// newTestBackfill returns the backfill that Run runs for c, whose loader stops
+// when stop is requested and reports its progress to progress.
+func newTestBackfill(t *testing.T, c Config, db *sql.DB, stop *shutdown, progress ProgressFunc) *backfill {
+	t.Helper()
+	files, err := inputFiles(c.Input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := dialect{kind: sqliteDialect}
+	warns := newWarnings(false)
+	return &backfill{
+		db:          db,
+		dialect:     d,
+		files:       files,
+		in:          inputOptions{format: csvInput},
+		transformer: &KickstartTransformer{warns: warns, filter: rowFilter{seen: make(map[int64]bool), skipped: &skipCounts{}}},
+		loader: &SQLLoader{
+			db:                 db,
+			dialect:            d,
+			tables:             schemaTables,
+			order:              defaultLoadOrder(),
+			batchSize:          1000,
+			dimensionBatchSize: 1000,
+			limit:              newThrottle(0),
+			stop:               stop,
+			Progress:           progress,
+		},
+		report: newLoadReport(),
+		warns:  warns,
+		errs:   &rowErrors{warns: warns},
+		stop:   stop,
+	}
+}
+
+func TestBackfillResume(t *testing.T) {
+	dir := testBackfillDir(t)
+	c, db := testDBConfig(t, dir)
+	c.Backfill = true
+	if err := Run(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	want := dumpTables(t, db)
+	if n := queryInt(t, db, "SELECT count(*) FROM kickstarts"); n != 8 {
+		t.Errorf("loaded %d kickstarts, want 8", n)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM etl_backfill_files"); n != 3 {
+		t.Errorf("etl_backfill_files has %d rows, want 3", n)
+	}
+	if n := queryInt(t, db, "SELECT count(DISTINCT file_name) FROM load_metadata"); n != 3 {
+		t.Errorf("load_metadata names %d files, want 3", n)
+	}
+
+	tests := []struct {
+		name string
+		// stopAt is the call of the progress of the loads at which the
+		// stop is requested. The progress is reported as each file
+		// starts loading and once it is loaded.
+		stopAt int
+		// rows are the kickstarts loaded when the backfill stops.
+		rows int
+	}{
+		{"between files", 2, 3},
+		{"in a file", 3, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, db := testDBConfig(t, dir)
+			c.Backfill = true
+			stop := &shutdown{done: make(chan struct{})}
+			calls := 0
+			b := newTestBackfill(t, c, db, stop, func(done, total int) {
+				if calls++; calls == tt.stopAt {
+					atomic.StoreInt32(&stop.requested, 1)
+				}
+			})
+			if _, err := b.run(context.Background()); err != ErrInterrupted {
+				t.Fatalf("interrupted backfill returned %v, want ErrInterrupted", err)
+			}
+			if n := queryInt(t, db, "SELECT count(*) FROM etl_backfill_files"); n != 1 {
+				t.Errorf("interrupted backfill recorded %d files, want 1", n)
+			}
+			if n := queryInt(t, db, "SELECT count(*) FROM kickstarts"); n != tt.rows {
+				t.Errorf("interrupted backfill loaded %d kickstarts, want %d", n, tt.rows)
+			}
+
+			if err := Run(context.Background(), c); err != nil {
+				t.Fatal(err)
+			}
+			if got := dumpTables(t, db); got != want {
+				t.Errorf("resumed backfill loaded\n%s\nwant\n%s", got, want)
+			}
+			if n := queryInt(t, db, "SELECT count(*) FROM etl_backfill_files"); n != 3 {
+				t.Errorf("etl_backfill_files has %d rows, want 3", n)
+			}
+		})
+	}
+}
+
+func TestBackfillChangedFile(t *testing.T) {
+	dir := testBackfillDir(t)
+	c, _ := testDBConfig(t, dir)
+	c.Backfill = true
+	if err := Run(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "2018-01.csv")
+	if err := os.WriteFile(path, []byte(testCSV(testRow(nil))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := Run(context.Background(), c)
+	if err == nil || !strings.Contains(err.Error(), "2018-01.csv changed") {
+		t.Fatalf("Run returned %v, want an error that 2018-01.csv changed", err)
+	}
+}
@@ -0,0 +1,55 @@
+package etl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// maxBatchSize returns the largest number of kickstarts rows that fit in one
+// INSERT statement of dialect d.
+func maxBatchSize(d dialect) int {
+	return d.maxPlaceholders() / len(kickstartColumns)
+}
+
+// factBatch collects kickstarts fact rows and inserts them with a single
+// multi-row INSERT statement once size rows have been added, which saves a
+// round trip to the database per row.
+type factBatch struct {
+	db   execer
+	d    dialect
+	size int
+	args []interface{}
+	rows int
+}
+
+func newFactBatch(db execer, d dialect, size int) *factBatch {
+	return &factBatch{db: db, d: d, size: size}
+}
+
+// add adds the fact row of k given the ids of its dimension rows, inserting
+// the batch if it is full.
+func (b *factBatch) add(ctx context.Context, k Kickstart, ids map[string]int64) error {
+	b.args = append(b.args, kickstartArgs(k, ids)...)
+	b.rows++
+	if b.rows >= b.size {
+		return b.flush(ctx)
+	}
+	return nil
+}
+
+// flush inserts the rows collected so far.
+func (b *factBatch) flush(ctx context.Context) error {
+	if b.rows == 0 {
+		return nil
+	}
+	start := time.Now()
+	if _, err := b.db.ExecContext(ctx, b.d.insert("kickstarts", b.rows, kickstartColumns...), b.args...); err != nil {
+		return fmt.Errorf("inserting %d rows into kickstarts: %w", b.rows, err)
+	}
+	slog.Debug("Inserted batch", "table", "kickstarts", "rows", b.rows, "duration", time.Since(start))
+	b.args = b.args[:0]
+	b.rows = 0
+	return nil
+}
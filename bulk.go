@@ -0,0 +1,107 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// factWriter collects kickstarts fact rows and inserts them. A factBatch
+// inserts them a batch at a time and a bulkLoad all at once.
+type factWriter interface {
+	// add adds the fact row of k given the ids of its dimension rows.
+	add(ctx context.Context, k Kickstart, ids map[string]int64) error
+	// flush inserts the rows added so far.
+	flush(ctx context.Context) error
+}
+
+// bulkLoad writes kickstarts fact rows to a temporary CSV file and inserts
+// them with a single MySQL LOAD DATA LOCAL INFILE statement on flush, which is
+// much faster than INSERT statements for a large load. The file is registered
+// with the driver so that no other local file can be read, but the server
+// still has to allow it with local_infile.
+//
+// The fact rows refer to the dimension rows by id, so the dimension rows have
+// to be inserted before their fact rows are added.
+type bulkLoad struct {
+	db   execer
+	d    dialect
+	f    *os.File
+	w    *csv.Writer
+	rows int
+}
+
+func newBulkLoad(db execer, d dialect) (*bulkLoad, error) {
+	f, err := os.CreateTemp("", "kickstarts-*.csv")
+	if err != nil {
+		return nil, err
+	}
+	mysql.RegisterLocalFile(f.Name())
+	return &bulkLoad{db: db, d: d, f: f, w: csv.NewWriter(f)}, nil
+}
+
+func (b *bulkLoad) add(ctx context.Context, k Kickstart, ids map[string]int64) error {
+	args := kickstartArgs(k, ids)
+	record := make([]string, len(args))
+	for i, a := range args {
+		record[i] = bulkValue(a)
+	}
+	b.rows++
+	return b.w.Write(record)
+}
+
+func (b *bulkLoad) flush(ctx context.Context) error {
+	if b.rows == 0 {
+		return nil
+	}
+	b.w.Flush()
+	if err := b.w.Error(); err != nil {
+		return err
+	}
+	start := time.Now()
+	query := fmt.Sprintf(`LOAD DATA LOCAL INFILE '%s' INTO TABLE %s
+	FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '"'
+	LINES TERMINATED BY '\n'
+	(%s)`, b.f.Name(), b.d.table("kickstarts"), strings.Join(kickstartColumns, ", "))
+	if _, err := b.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("loading %d rows into kickstarts: %w", b.rows, err)
+	}
+	slog.Debug("Loaded file", "table", "kickstarts", "rows", b.rows, "duration", time.Since(start))
+	b.rows = 0
+	if err := b.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := b.f.Seek(0, 0)
+	return err
+}
+
+// close removes the temporary file.
+func (b *bulkLoad) close() error {
+	mysql.DeregisterLocalFile(b.f.Name())
+	b.f.Close()
+	return os.Remove(b.f.Name())
+}
+
+// bulkValue formats a value of kickstartArgs as a field of the file read by
+// LOAD DATA.
+func bulkValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case sql.NullFloat64:
+		if !v.Valid {
+			return `\N`
+		}
+		return strconv.FormatFloat(v.Float64, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,67 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// checkpointTable records the last kickstart committed by a load so that an
+// interrupted load can be resumed with --resume. It holds a single row.
+var checkpointTable = table{
+	Name: "etl_checkpoint",
+	Columns: []column{
+		{Name: "kickstarter_id", Type: "INT"},
+		{Name: "row_count", Type: "INT"},
+		{Name: "saved_at", Type: "DATETIME"},
+	},
+}
+
+// saveCheckpoint records that the rows rows ending with k were loaded. It
+// must be called with the transaction of the load so that the checkpoint is
+// committed along with the rows.
+func saveCheckpoint(ctx context.Context, db execer, d dialect, k Kickstart, rows int) error {
+	if rows == 0 {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM "+d.table(checkpointTable.Name)); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, d.insert(checkpointTable.Name, 1, "kickstarter_id", "row_count", "saved_at"), k.Product.KickstarterID, rows, d.timeArg(time.Now().UTC().Round(0)))
+	return err
+}
+
+// readCheckpoint returns the kickstarter_id of the last kickstart committed by
+// a load and the number of rows that load committed. The id is 0 if no load
+// has saved a checkpoint.
+func readCheckpoint(ctx context.Context, db *sql.DB, d dialect) (int64, int, error) {
+	var (
+		kickstarterID int64
+		rows          int
+	)
+	query := "SELECT kickstarter_id, row_count FROM " + d.table(checkpointTable.Name)
+	err := db.QueryRowContext(ctx, query).Scan(&kickstarterID, &rows)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, 0, err
+	}
+	return kickstarterID, rows, nil
+}
+
+// loadedKickstarterIDs returns the kickstarter_id of every product that is
+// already loaded. The ids are unique as products.kickstarter_id is.
+func loadedKickstarterIDs(ctx context.Context, db *sql.DB, d dialect) (map[int64]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT kickstarter_id FROM "+d.table("products"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
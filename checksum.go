@@ -0,0 +1,47 @@
+package etl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// fileChecksum returns the sha256 checksum of the file at path in the form
+// "sha256:<hex>".
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyChecksum checks the file at path against spec which is either
+// "sha256" to only compute the checksum or "sha256:<hex>" to also compare it
+// with the expected value. It returns the computed checksum.
+func verifyChecksum(path, spec string) (string, error) {
+	algo, want := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		algo, want = spec[:i], strings.ToLower(spec[i+1:])
+	}
+	if algo != "sha256" {
+		return "", fmt.Errorf("unsupported checksum algorithm %q (must be sha256)", algo)
+	}
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return "", err
+	}
+	if want != "" && sum != "sha256:"+want {
+		return sum, fmt.Errorf("checksum mismatch for %s: got %s, want sha256:%s", path, sum, want)
+	}
+	return sum, nil
+}
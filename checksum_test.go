@@ -0,0 +1,49 @@
+package etl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestRunChecksum(t *testing.T) {
+	in := testCSV(testRow(nil))
+	h := sha256.Sum256([]byte(in))
+	sum := "sha256:" + hex.EncodeToString(h[:])
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  string
+	}{
+		{"matching", sum, ""},
+		{"upper case hex", "sha256:" + strings.ToUpper(sum[len("sha256:"):]), ""},
+		{"computed", "sha256", ""},
+		{"mismatching", "sha256:" + strings.Repeat("0", 64), "checksum mismatch"},
+		{"unknown algorithm", "md5:d41d8cd98f00b204e9800998ecf8427e", `unsupported checksum algorithm "md5"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, db := testDBConfig(t, writeTestFile(t, "in.csv", in))
+			c.Checksum = tt.checksum
+			err := Run(context.Background(), c)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run returned %v, want an error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if err := db.QueryRow("SELECT file_checksum FROM load_metadata").Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != sum {
+				t.Errorf("load_metadata has checksum %s, want %s", got, sum)
+			}
+		})
+	}
+}
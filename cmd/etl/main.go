@@ -0,0 +1,183 @@
+// Command etl loads the kickstarter dataset into a star schema.
+//
+// An interrupt (Ctrl-C) stops the load once the current row is done and
+// commits the rows loaded so far; a second interrupt rolls back the
+// uncommitted rows instead. The exit status is:
+//
+//	0    the run succeeded
+//	1    the run failed, any uncommitted rows were rolled back
+//	2    the flags are invalid
+//	130  an interrupt stopped the run, the rows loaded before it are
+//	     committed, or written for the file outputs; --resume loads the
+//	     rest of a plain load and --backfill that of a backfill
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/psimika/etl"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// exitUsage is the exit status of invalid flags, the same as the flag package
+// exits with when it cannot parse them.
+const exitUsage = 2
+
+// exitInterrupted is the exit status of a load stopped by an interrupt, 128
+// plus the number of SIGINT as shells report it.
+const exitInterrupted = 130
+
+func main() {
+	var (
+		c         etl.Config
+		verbose   = flag.Bool("verbose", false, "log debug messages such as the progress of the load and the time each batch takes")
+		logFormat = flag.String("log-format", "text", "format of the log messages: text or json")
+		version   = flag.Bool("version", false, "print the version, VCS revision and build time of the program and exit")
+	)
+	flag.StringVar(&c.Driver, "driver", "mysql", "database to load into: mysql, postgres or sqlite")
+	flag.StringVar(&c.DataSource, "datasource", "", "database configuration (default $"+etl.DataSourceEnv+" or else depends on --driver)")
+	flag.StringVar(&c.Input, "input", "kickstarter-data/ks-projects-201801.csv.zip", "path to the input data: a .zip, a .csv.gz or a plain .csv file, a directory of such files loaded together in the order of their names, an http, https or s3://bucket/key URL of one, or - to read it from stdin")
+	flag.BoolVar(&c.Delete, "delete", false, "delete all tables")
+	flag.BoolVar(&c.Merge, "merge", false, "merge into existing tables: insert new rows, update changed rows and skip unchanged rows")
+	flag.StringVar(&c.ExportSchema, "export-schema", "", "print the schema as json or dot (Graphviz) and exit")
+	flag.StringVar(&c.LoadOrder, "load-order", "", "comma separated order in which tables are inserted (default dimensions first, then kickstarts)")
+	flag.BoolVar(&c.RenameDuplicateProducts, "rename-duplicate-products", false, "load every occurrence of a duplicate kickstarter_id as a separate product")
+	flag.StringVar(&c.Output, "output", "db", "where to write the data: db to load it into the database, csv to stream it as cleaned CSV, json to stream it as newline-delimited JSON or parquet to write a Parquet file")
+	flag.StringVar(&c.Out, "out", "", "file to write the csv, json or parquet output to (default stdout)")
+	flag.BoolVar(&c.QuoteAll, "quote-all", false, "quote every field of the csv output")
+	flag.BoolVar(&c.ValidateStates, "validate-states", true, "normalize states and report the rows whose state is not one of --allowed-states")
+	flag.BoolVar(&c.Strict, "strict", false, "fail on the first row whose state is not one of --allowed-states instead of reporting it")
+	flag.StringVar(&c.AllowedStates, "allowed-states", etl.KnownStates, "comma separated states accepted by --validate-states")
+	flag.Float64Var(&c.TargetRPS, "target-rps", 0, "load at most this many rows per second (0 means unlimited)")
+	flag.BoolVar(&c.WarnImmediately, "warn-immediately", false, "log each data quality warning to stderr as it occurs")
+	flag.BoolVar(&c.FailOnWarnings, "fail-on-warnings", false, "exit with an error if any data quality warning occurred")
+	flag.StringVar(&c.Checksum, "checksum", "", "verify the input file against sha256:<hex> before processing, or print its checksum if only sha256 is given")
+	flag.StringVar(&c.SourceVersion, "source-version", "", "version of the dataset recorded in load_metadata")
+	flag.BoolVar(&c.ValidateForeignKeys, "validate-foreign-keys", false, "check the loaded tables for rows with dangling foreign keys and exit")
+	flag.BoolVar(&c.Stream, "stream", false, "load rows one at a time as they are read instead of reading the whole file first")
+	flag.IntVar(&c.BatchSize, "fact-batch-size", 1000, "number of kickstarts rows inserted by each INSERT statement; with mysql a statement must fit in max_allowed_packet (4MB in 5.7, 64MB in 8.0), at about 200 bytes a row the default takes 200KB")
+	flag.IntVar(&c.BatchSize, "batch-size", 1000, "old name of --fact-batch-size")
+	flag.IntVar(&c.MaxLookupValues, "max-lookup-values", 100000, "warn when a lookup table, such as the countries, has more distinct values than this; 0 disables the warning")
+	flag.BoolVar(&c.LookupFallback, "lookup-fallback", false, "stop caching the values of a lookup table past --max-lookup-values and look them up in the database instead, which bounds the memory of the load at the cost of a query per row")
+	flag.IntVar(&c.DimensionBatchSize, "dimension-batch-size", 1000, "number of distinct values of a lookup table, such as the countries, inserted by each INSERT statement before the kickstarts rows, at most 255 bytes a value in max_allowed_packet; --stream, --merge and --upsert insert one value at a time")
+	flag.BoolVar(&c.SkipErrors, "skip-errors", false, "skip rows that cannot be parsed and report them at the end instead of stopping at the first one")
+	flag.IntVar(&c.Limit, "limit", 0, "process only the first N data rows of the input (0 means all); an http, https or s3 input stops downloading once they are read, except a .zip which is downloaded whole")
+	flag.BoolVar(&c.DryRun, "dry-run", false, "extract and transform the input and print a sample of the result without touching the database")
+	flag.BoolVar(&c.Yes, "yes", false, "do not ask for confirmation before --delete")
+	flag.StringVar(&c.Database, "database", "", "name of the database the tables are loaded into (default parsed from --datasource)")
+	flag.IntVar(&c.Workers, "workers", 1, "number of connections that insert the rows in parallel")
+	flag.IntVar(&c.ExtractWorkers, "extract-workers", 1, "number of files of an --input directory extracted in parallel")
+	flag.BoolVar(&c.Backfill, "backfill", false, "load the files of an --input directory one after the other, each recorded in load_metadata, skipping the files an earlier --backfill loaded; an interrupted backfill run again loads the rest")
+	flag.IntVar(&c.MaxRetries, "max-retries", 3, "number of times a load that fails with a MySQL deadlock or lock wait timeout is retried")
+	flag.BoolVar(&c.Bulk, "bulk", false, "insert the kickstarts rows with LOAD DATA LOCAL INFILE which needs local_infile enabled on the MySQL server")
+	flag.StringVar(&c.InputFormat, "input-format", "csv", "format of the input: csv for the kickstarter CSV, jsonl for one JSON object per line or xlsx for the first sheet of an Excel workbook with the columns of the CSV")
+	flag.StringVar(&c.Delimiter, "delimiter", ",", "character that separates the fields of the csv input, tab for a tab or auto to detect it from the header")
+	flag.DurationVar(&c.Timeout, "timeout", 0, "time limit for fetching an http or https --input, including reading it (0 means no limit)")
+	flag.StringVar(&c.Report, "report", "text", "format of the summary of a database load: text to log it or json to write it to stdout")
+	flag.BoolVar(&c.Append, "append", false, "load into the tables of the ETL even if they already exist, adding to their rows")
+	flag.StringVar(&c.TablePrefix, "table-prefix", "", "prefix of the names of all the tables, e.g. s2018_ to keep each snapshot in its own set of tables")
+	flag.StringVar(&c.Rates, "rates", "", "file of exchange rates to a reporting currency, a .json object of currency to rate or a currency,rate CSV, used to fill goal_reporting and pledged_reporting")
+	flag.StringVar(&c.States, "state", "", "comma separated states to load, e.g. successful; the other rows are filtered out (default all)")
+	flag.StringVar(&c.Countries, "country", "", "comma separated countries to load as they appear in the data, e.g. US,GB; combined with --state a row must match both (default all)")
+	flag.StringVar(&c.From, "from", "", "load only the campaigns launched on or after this RFC 3339 date, e.g. 2015-01-01")
+	flag.StringVar(&c.To, "to", "", "load only the campaigns launched on or before this RFC 3339 date, e.g. 2016-12-31")
+	flag.BoolVar(&c.Resume, "resume", false, "continue an interrupted load: skip the rows whose kickstarter_id is already loaded and load the rest")
+	flag.BoolVar(&c.Upsert, "upsert", false, "insert new rows and update the rows whose kickstarter_id is already loaded in place; lookup values no row uses any more are deleted")
+	flag.StringVar(&c.SchemaOut, "schema-out", "", "write the CREATE TABLE and CREATE INDEX statements for --driver to this file, or - for stdout, and exit without running them")
+	flag.IntVar(&c.MaxOpenConns, "max-open-conns", 0, "most connections open to the database (default --workers + 1)")
+	flag.IntVar(&c.MaxIdleConns, "max-idle-conns", 0, "most idle connections kept open (default --max-open-conns)")
+	flag.DurationVar(&c.ConnMaxLifetime, "conn-max-lifetime", 30*time.Minute, "longest time a connection is reused before it is closed (0 means forever)")
+	flag.BoolVar(&c.CountOnly, "count-only", false, "extract and transform the input, print the number of rows and of distinct values of each lookup table and exit without loading")
+	flag.StringVar(&c.Encoding, "encoding", "utf-8", "character encoding of the input: utf-8, latin1 or windows-1252; invalid UTF-8 is replaced with U+FFFD")
+	flag.BoolVar(&c.LazyQuotes, "lazy-quotes", false, "accept bare double quotes inside csv fields, such as in The 6\" Figure, instead of failing on them")
+	flag.BoolVar(&c.Truncate, "truncate", false, "empty the tables before loading, keeping the tables with their indexes and grants, instead of dropping them like --delete")
+	flag.StringVar(&c.DedupKeep, "dedup-keep", "first", "which occurrence of a repeated kickstarter_id to load, first or last, with a warning for the others; --merge, --upsert and --rename-duplicate-products keep them all")
+	flag.IntVar(&c.Generate, "generate", 0, "write this many rows of a synthetic dataset, the same rows every time, to --out and exit; load it to benchmark the ETL against the same input")
+	flag.Parse()
+
+	if *version {
+		printVersion(os.Stdout)
+		return
+	}
+
+	logger, err := newLogger(*logFormat, *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	slog.SetDefault(logger)
+
+	if err := etl.Run(context.Background(), c); err != nil {
+		if errors.Is(err, etl.ErrInterrupted) {
+			fmt.Fprintln(os.Stderr, "Interrupted: stopped early, the rows loaded or written before the interrupt are kept")
+			os.Exit(exitInterrupted)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, etl.ErrInvalidConfig) {
+			os.Exit(exitUsage)
+		}
+		os.Exit(1)
+	}
+}
+
+// newLogger returns a logger that writes to stderr in the given format, text
+// or json. Debug messages are only logged if verbose is true.
+func newLogger(format string, verbose bool) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if verbose {
+		opts.Level = slog.LevelDebug
+	}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (must be text or json)", format)
+	}
+}
+
+// printVersion writes the module version, VCS revision and build time of the
+// binary to w. The build time is the time of the revision as Go does not
+// record the time of the build itself.
+func printVersion(w io.Writer) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		fmt.Fprintln(w, "etl: no build information available")
+		return
+	}
+	var revision, built, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.time":
+			built = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = " (modified)"
+			}
+		}
+	}
+	if revision == "" {
+		revision = "unknown"
+	}
+	if built == "" {
+		built = "unknown"
+	}
+	fmt.Fprintf(w, "etl %s\n", info.Main.Version)
+	fmt.Fprintf(w, "revision: %s%s\n", revision, modified)
+	fmt.Fprintf(w, "built: %s\n", built)
+	fmt.Fprintf(w, "go: %s\n", info.GoVersion)
+}
@@ -0,0 +1,50 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// pingTimeout is how long pingDatabase waits for the database to answer.
+const pingTimeout = 5 * time.Second
+
+// pingDatabase connects to db so that a bad data source is reported before
+// any work starts rather than by the first statement. sql.Open does not
+// connect by itself.
+func pingDatabase(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	err := db.PingContext(ctx)
+	if err == nil {
+		return nil
+	}
+
+	var (
+		netErr   net.Error
+		mysqlErr *mysql.MySQLError
+		pqErr    *pq.Error
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("database did not answer within %v, check the host and port of the datasource", pingTimeout)
+	case errors.As(err, &mysqlErr) && (mysqlErr.Number == 1044 || mysqlErr.Number == 1045):
+		return fmt.Errorf("access denied by the database, check the user and password of the datasource: %v", err)
+	case errors.As(err, &mysqlErr) && mysqlErr.Number == 1049:
+		return fmt.Errorf("unknown database, check the database name of the datasource: %v", err)
+	case errors.As(err, &pqErr) && (pqErr.Code == "28000" || pqErr.Code == "28P01"):
+		return fmt.Errorf("access denied by the database, check the user and password of the datasource: %v", err)
+	case errors.As(err, &pqErr) && pqErr.Code == "3D000":
+		return fmt.Errorf("unknown database, check the database name of the datasource: %v", err)
+	case errors.As(err, &netErr):
+		return fmt.Errorf("cannot reach the database, check the host and port of the datasource: %v", err)
+	default:
+		return fmt.Errorf("connecting to database: %v", err)
+	}
+}
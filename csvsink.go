@@ -0,0 +1,229 @@
+package etl
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// csvHeader is the header of the cleaned CSV written by csvSink.
+var csvHeader = []string{
+	"kickstarter_id",
+	"name",
+	"category",
+	"main_category",
+	"currency",
+	"deadline",
+	"launched",
+	"state",
+	"country",
+	"backers",
+	"goal",
+	"goal_usd_real",
+	"pledged",
+	"pledged_usd",
+	"pledged_usd_real",
+	"duration_days",
+	"funding_pct",
+	"goal_reporting",
+	"pledged_reporting",
+}
+
+// recordWriter is implemented by csv.Writer and quotingWriter.
+type recordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// quotingWriter writes CSV records with every field quoted, which some strict
+// CSV parsers require. csv.Writer only quotes fields when necessary.
+type quotingWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func newQuotingWriter(w io.Writer) *quotingWriter {
+	return &quotingWriter{w: bufio.NewWriter(w)}
+}
+
+func (w *quotingWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	for i, field := range record {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		w.w.WriteByte('"')
+		w.w.WriteString(strings.Replace(field, `"`, `""`, -1))
+		if _, err := w.w.WriteString(`"`); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	_, w.err = w.w.WriteString("\n")
+	return w.err
+}
+
+func (w *quotingWriter) Flush() {
+	if err := w.w.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *quotingWriter) Error() error {
+	return w.err
+}
+
+// csvSink writes kickstarts as flat CSV records, one at a time.
+type csvSink struct {
+	w recordWriter
+}
+
+// newCSVSink returns a sink that writes to w. If quoteAll is true every field
+// is quoted, otherwise fields are only quoted when necessary.
+func newCSVSink(w io.Writer, quoteAll bool) (*csvSink, error) {
+	s := &csvSink{w: csv.NewWriter(w)}
+	if quoteAll {
+		s.w = newQuotingWriter(w)
+	}
+	if err := s.w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *csvSink) Write(k Kickstart) error {
+	return s.w.Write([]string{
+		strconv.FormatInt(k.Product.KickstarterID, 10),
+		k.Product.Name,
+		k.Category.Name,
+		k.MainCategory.Name,
+		k.Currency.Type,
+		k.Date.Deadline.Format(deadlineLayout),
+		k.Date.Launched.Format(launchedLayout),
+		k.State.State,
+		k.Area.Country,
+		strconv.Itoa(k.Backers),
+		formatFloat(k.Goal),
+		formatNullFloat(k.GoalUSDReal),
+		formatFloat(k.Pledged),
+		formatNullFloat(k.PledgedUSD),
+		formatNullFloat(k.PledgedUSDReal),
+		strconv.Itoa(k.DurationDays),
+		formatNullFloat(k.FundingPct),
+		formatNullFloat(k.GoalReporting),
+		formatNullFloat(k.PledgedReporting),
+	})
+}
+
+// Flush writes any buffered records and reports any error that happened while
+// writing.
+func (s *csvSink) Flush() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// formatNullFloat formats f like formatFloat or as an empty field if it is
+// NULL.
+func formatNullFloat(f sql.NullFloat64) string {
+	if !f.Valid {
+		return ""
+	}
+	return formatFloat(f.Float64)
+}
+
+// sink writes kickstarts one at a time to a file format such as CSV or JSON.
+type sink interface {
+	Write(k Kickstart) error
+	// Flush writes any buffered kickstarts.
+	Flush() error
+}
+
+// sinkOptions configure writeFile and streamSink.
+type sinkOptions struct {
+	in     inputOptions
+	filter rowFilter
+	// allowedStates are the states accepted when states are validated. A nil
+	// map disables validation.
+	allowedStates map[string]bool
+	// strictStates makes an unknown state an error.
+	strictStates bool
+	// rates convert the goal and pledged to the reporting currency. Nil rates
+	// disable the conversion.
+	rates exchangeRates
+	warns *warnings
+	errs  *rowErrors
+	stop  *shutdown
+}
+
+// writeFile streams the data from r to the file at path, or to stdout if path
+// is empty, in the format of the sink returned by newSink.
+func writeFile(ctx context.Context, r io.Reader, path string, newSink func(io.Writer) (sink, error), opts sinkOptions) error {
+	if path == "" {
+		return streamSink(ctx, r, os.Stdout, newSink, opts)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := streamSink(ctx, r, f, newSink, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// streamSink reads the raw kickstarter data from r and writes it cleaned to w
+// through the sink returned by newSink. Each row flows through extraction,
+// transformation and the sink on its own so memory use does not grow with the
+// number of rows. Only the kickstarts that pass opts.filter are written and
+// their states are validated and their amounts converted to the reporting
+// currency like those loaded into the database. If a stop is requested, the
+// rows written so far are flushed before returning ErrInterrupted.
+func streamSink(ctx context.Context, r io.Reader, w io.Writer, newSink func(io.Writer) (sink, error), opts sinkOptions) error {
+	s, err := newSink(w)
+	if err != nil {
+		return err
+	}
+	written := 0
+	err = eachKickstart(ctx, r, opts.in, opts.filter, opts.warns, opts.errs, func(k Kickstart) error {
+		if opts.stop.stopRequested() {
+			return ErrInterrupted
+		}
+		if opts.allowedStates != nil && !validateState(&k, opts.allowedStates, opts.warns) && opts.strictStates {
+			return unknownStateError(k)
+		}
+		if opts.rates != nil {
+			convertCurrency(&k, opts.rates, opts.warns)
+		}
+		if err := s.Write(k); err != nil {
+			return fmt.Errorf("writing output: %v", err)
+		}
+		written++
+		return nil
+	})
+	if err == ErrInterrupted {
+		if err := s.Flush(); err != nil {
+			return err
+		}
+		slog.Info("Stopped writing", "rows", written)
+		return ErrInterrupted
+	}
+	if err != nil {
+		return err
+	}
+	return s.Flush()
+}
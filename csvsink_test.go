@@ -0,0 +1,100 @@
+package etl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCSVSinkQuoteAll(t *testing.T) {
+	kk := testKickstarts(t, testRow(map[string]string{"name": `"The 6"" Figure"`}))
+	tests := []struct {
+		quoteAll bool
+		want     []string
+	}{
+		{false, []string{`1000003930,"The 6"" Figure",Narrative Film,`, `,15,30000.00,`}},
+		{true, []string{`"kickstarter_id","name",`, `"1000003930","The 6"" Figure","Narrative Film",`, `,"15","30000.00",`}},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		s, err := newCSVSink(&b, tt.quoteAll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Write(kk[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("quoteAll %v wrote\n%s\nwant it to contain %s", tt.quoteAll, b.String(), want)
+			}
+		}
+	}
+}
+
+// heapSink writes to its sink and records the most heap in use, sampled every
+// 10000 rows.
+type heapSink struct {
+	sink
+	rows int
+	max  uint64
+}
+
+func (s *heapSink) Write(k Kickstart) error {
+	if s.rows++; s.rows%10000 == 0 {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		if m.HeapInuse > s.max {
+			s.max = m.HeapInuse
+		}
+	}
+	return s.sink.Write(k)
+}
+
+// BenchmarkStreamSink writes synthetic files of growing sizes as CSV. The
+// max-heap-MB it reports stays about the same for every size as the rows are
+// written while they are extracted. The kickstarter_ids are not deduplicated
+// as the set of those seen grows with the rows.
+func BenchmarkStreamSink(b *testing.B) {
+	for _, n := range []int{10000, 100000, 500000} {
+		b.Run(fmt.Sprintf("rows=%d", n), func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "ks.csv")
+			if err := writeSyntheticFile(path, n); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			var max uint64
+			for i := 0; i < b.N; i++ {
+				f, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				warns := newWarnings(false)
+				hs := &heapSink{}
+				newSink := func(w io.Writer) (sink, error) {
+					s, err := newCSVSink(w, false)
+					hs.sink = s
+					return hs, err
+				}
+				opts := sinkOptions{in: inputOptions{format: csvInput}, warns: warns, errs: &rowErrors{warns: warns}}
+				err = streamSink(context.Background(), f, io.Discard, newSink, opts)
+				f.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if hs.max > max {
+					max = hs.max
+				}
+			}
+			b.ReportMetric(float64(max)/(1<<20), "max-heap-MB")
+		})
+	}
+}
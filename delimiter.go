@@ -0,0 +1,50 @@
+package etl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// delimiterCandidates are the delimiters considered when the delimiter of the
+// CSV input is detected, in order of preference when they are equally common.
+var delimiterCandidates = []rune{',', ';', '\t', '|'}
+
+// parseDelimiter parses the value of --delimiter which is a single character,
+// tab or \t for a tab, or auto to detect the delimiter from the header. It
+// returns the delimiter and whether it is to be detected.
+func parseDelimiter(s string) (rune, bool, error) {
+	switch s {
+	case "auto":
+		return 0, true, nil
+	case "tab", `\t`:
+		return '\t', false, nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, false, fmt.Errorf("invalid delimiter %q (must be a single character, tab or auto)", s)
+	}
+	return r, false, nil
+}
+
+// sniffDelimiter returns the candidate delimiter that occurs most often in the
+// header line at the start of br without consuming it. It returns a comma if
+// none of them occurs.
+func sniffDelimiter(br *bufio.Reader) (rune, error) {
+	b, err := br.Peek(br.Size())
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[:i]
+	}
+	best, bestCount := ',', 0
+	for _, c := range delimiterCandidates {
+		if n := bytes.Count(b, []byte(string(c))); n > bestCount {
+			best, bestCount = c, n
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,78 @@
+package etl
+
+import "fmt"
+
+// There are three ways to handle a kickstarter_id that appears more than
+// once. Merging (see mergeData) treats the natural key as the identity of a
+// campaign so later occurrences update the earlier one. Renaming treats every
+// occurrence as a genuinely different campaign, for example a re-launch, so
+// they are all loaded as separate products. Otherwise only one occurrence is
+// loaded, the first (see rowFilter.seen) or the last (see keepLastDuplicates),
+// as a repeated kickstarter_id would abort the load.
+
+// Occurrences of a repeated kickstarter_id that can be kept.
+const (
+	dedupFirst = "first"
+	dedupLast  = "last"
+)
+
+// keepLastDuplicates drops every occurrence of a kickstarter_id in kk but the
+// last one, with a warning. The kickstarts that are kept stay in order.
+func keepLastDuplicates(kk []Kickstart, warns *warnings) []Kickstart {
+	last := make(map[int64]int, len(kk))
+	for i, k := range kk {
+		last[k.Product.KickstarterID] = i
+	}
+	if len(last) == len(kk) {
+		return kk
+	}
+	out := kk[:0]
+	for i, k := range kk {
+		id := k.Product.KickstarterID
+		if last[id] != i {
+			warns.warn("duplicate kickstarter_id", "kickstarter_id %d appears more than once, keeping the last occurrence", id)
+			continue
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+// renameDuplicateProducts appends a sequence suffix to the name of every
+// repeated occurrence of a kickstarter_id in kk so that each occurrence can be
+// told apart, e.g. the second "Name" becomes "Name (2)". It returns the number
+// of products that were renamed.
+func renameDuplicateProducts(kk []Kickstart) int {
+	seen := make(map[int64]int)
+	renamed := 0
+	for i := range kk {
+		id := kk[i].Product.KickstarterID
+		seen[id]++
+		if n := seen[id]; n > 1 {
+			kk[i].Product.Name = fmt.Sprintf("%s (%d)", kk[i].Product.Name, n)
+			renamed++
+		}
+	}
+	return renamed
+}
+
+// allowDuplicateProducts returns a copy of tables where products.kickstarter_id
+// is no longer unique so that duplicate occurrences can be inserted.
+func allowDuplicateProducts(tables []table) []table {
+	out := make([]table, len(tables))
+	copy(out, tables)
+	for i, t := range out {
+		if t.Name != "products" {
+			continue
+		}
+		cols := make([]column, len(t.Columns))
+		copy(cols, t.Columns)
+		for j := range cols {
+			if cols[j].Name == "kickstarter_id" {
+				cols[j].Unique = false
+			}
+		}
+		out[i].Columns = cols
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package etl
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateProducts(t *testing.T) {
+	in := testCSV(
+		testRow(map[string]string{"ID": "7", "name": "Launch"}),
+		testRow(map[string]string{"ID": "8", "name": "Other"}),
+		testRow(map[string]string{"ID": "7", "name": "Relaunch"}),
+	)
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{"keep first", func(c *Config) {}, []string{"Launch", "Other"}},
+		{"keep last", func(c *Config) { c.DedupKeep = dedupLast }, []string{"Other", "Relaunch"}},
+		{"rename", func(c *Config) { c.RenameDuplicateProducts = true }, []string{"Launch", "Other", "Relaunch (2)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, db := testDBConfig(t, writeTestFile(t, "in.csv", in))
+			tt.change(&c)
+			if err := Run(context.Background(), c); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := db.Query("SELECT p.name FROM kickstarts k JOIN products p ON p.id = k.product_id ORDER BY k.id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var got []string
+			for rows.Next() {
+				var name string
+				if err := rows.Scan(&name); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, name)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loaded products %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1443 @@
+package etl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DataSourceEnv is the environment variable the data source is read from when
+// Config.DataSource is empty.
+const DataSourceEnv = "ETL_DATASOURCE"
+
+// Config configures Run. Its fields correspond to the flags of the etl
+// command.
+type Config struct {
+	Driver                  string
+	DataSource              string
+	Input                   string
+	Delete                  bool
+	Merge                   bool
+	ExportSchema            string
+	LoadOrder               string
+	RenameDuplicateProducts bool
+	Output                  string
+	Out                     string
+	QuoteAll                bool
+	ValidateStates          bool
+	AllowedStates           string
+	TargetRPS               float64
+	WarnImmediately         bool
+	FailOnWarnings          bool
+	Checksum                string
+	SourceVersion           string
+	ValidateForeignKeys     bool
+	Stream                  bool
+	BatchSize               int
+	SkipErrors              bool
+	Limit                   int
+	DryRun                  bool
+	Yes                     bool
+	Database                string
+	Workers                 int
+	MaxRetries              int
+	Bulk                    bool
+	Strict                  bool
+	InputFormat             string
+	Delimiter               string
+	Timeout                 time.Duration
+	Report                  string
+	Append                  bool
+	TablePrefix             string
+	Rates                   string
+	States                  string
+	Countries               string
+	From                    string
+	To                      string
+	Resume                  bool
+	Upsert                  bool
+	SchemaOut               string
+	CountOnly               bool
+	Encoding                string
+	LazyQuotes              bool
+	MaxOpenConns            int
+	MaxIdleConns            int
+	ConnMaxLifetime         time.Duration
+	Truncate                bool
+	// DedupKeep is which occurrence of a repeated kickstarter_id is loaded:
+	// first or last. Empty means first.
+	DedupKeep string
+	// Generate is the number of rows of the synthetic dataset to write to
+	// Out instead of running the ETL. Zero runs the ETL.
+	Generate int
+	// DimensionBatchSize is the number of values of a lookup table inserted
+	// by each statement, while BatchSize is the number of fact rows. Zero
+	// means 1000.
+	DimensionBatchSize int
+	// MaxLookupValues is the number of distinct values of a lookup table
+	// above which a warning is logged. Zero means no limit. LookupFallback
+	// stops caching the values of a lookup table past it and looks them up
+	// in the database instead, which bounds the memory of the load.
+	MaxLookupValues int
+	LookupFallback  bool
+	// ExtractWorkers is the number of files of an Input directory that are
+	// extracted at once. Zero means 1.
+	ExtractWorkers int
+	// Backfill loads the files of an Input directory one after the other
+	// and skips the files that an earlier backfill loaded, see backfill.
+	Backfill bool
+}
+
+// ErrInvalidConfig is matched, with errors.Is, by the errors Run returns for
+// an invalid Config such as a flag out of range or flags that cannot be used
+// together. Run returns them before it touches the input or the database.
+var ErrInvalidConfig = errors.New("invalid config")
+
+// configError is an error in the Config given to Run.
+type configError struct{ err error }
+
+func (e configError) Error() string { return e.err.Error() }
+
+func (e configError) Is(target error) bool { return target == ErrInvalidConfig }
+
+// invalidf formats a configError.
+func invalidf(format string, a ...any) error {
+	return configError{fmt.Errorf(format, a...)}
+}
+
+// Run extracts, transforms and loads the kickstarter dataset as configured
+// by c. Cancelling ctx stops the load and rolls back its transaction.
+func Run(ctx context.Context, c Config) error {
+	d, err := parseDialect(c.Driver)
+	if err != nil {
+		return configError{err}
+	}
+	dataSourceFrom := "flag"
+	if c.DataSource == "" {
+		// The environment keeps the password out of the command line where
+		// ps and the shell history would show it.
+		c.DataSource, dataSourceFrom = os.Getenv(DataSourceEnv), "env"
+	}
+	if c.DataSource == "" {
+		c.DataSource, dataSourceFrom = d.defaultDataSource(), "default"
+	}
+	if d, err = d.withPrefix(c.TablePrefix); err != nil {
+		return configError{err}
+	}
+
+	if max := maxBatchSize(d); c.BatchSize < 1 || c.BatchSize > max {
+		return invalidf("--fact-batch-size must be between 1 and %d", max)
+	}
+	if c.DimensionBatchSize == 0 {
+		c.DimensionBatchSize = 1000
+	}
+	if max := d.maxPlaceholders(); c.DimensionBatchSize < 1 || c.DimensionBatchSize > max {
+		return invalidf("--dimension-batch-size must be between 1 and %d", max)
+	}
+
+	if c.Workers < 1 {
+		return invalidf("--workers must be at least 1")
+	}
+	if c.ExtractWorkers == 0 {
+		c.ExtractWorkers = 1
+	}
+	if c.ExtractWorkers < 1 {
+		return invalidf("--extract-workers must be at least 1")
+	}
+	if c.MaxLookupValues < 0 {
+		return invalidf("--max-lookup-values cannot be negative")
+	}
+	if c.LookupFallback && c.Workers > 1 {
+		return invalidf("--lookup-fallback cannot be used with --workers which share a lookup cache that holds every value")
+	}
+	if c.Workers > 1 && (c.Merge || c.Stream) {
+		return invalidf("--workers cannot be used with --merge or --stream")
+	}
+	if c.Workers > 1 && d.kind == sqliteDialect {
+		return invalidf("--workers cannot be used with sqlite which allows a single writer")
+	}
+
+	if c.Bulk && d.kind != mysqlDialect {
+		return invalidf("--bulk is only supported with mysql")
+	}
+	if c.Bulk && (c.Merge || c.Stream || c.Workers > 1) {
+		return invalidf("--bulk cannot be used with --merge, --stream or --workers")
+	}
+
+	if c.MaxRetries < 0 {
+		return invalidf("--max-retries cannot be negative")
+	}
+
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 || c.ConnMaxLifetime < 0 {
+		return invalidf("--max-open-conns, --max-idle-conns and --conn-max-lifetime cannot be negative")
+	}
+	if c.MaxOpenConns == 0 {
+		// Every worker holds a connection for its transaction and one more is
+		// needed for the statements around them.
+		c.MaxOpenConns = c.Workers + 1
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = c.MaxOpenConns
+	}
+	if c.Workers > 1 && c.MaxOpenConns < c.Workers+1 {
+		return invalidf("--max-open-conns must be at least %d for %d workers", c.Workers+1, c.Workers)
+	}
+
+	if c.Limit < 0 {
+		return invalidf("--limit must not be negative")
+	}
+
+	if c.Stream && (c.Merge || c.RenameDuplicateProducts) {
+		return invalidf("--stream cannot be used with --merge or --rename-duplicate-products which need all rows up front")
+	}
+
+	if c.InputFormat != csvInput && c.InputFormat != jsonLinesInput && c.InputFormat != xlsxInput {
+		return invalidf("unknown input format %q (must be csv, jsonl or xlsx)", c.InputFormat)
+	}
+
+	in := inputOptions{format: c.InputFormat, maxRows: c.Limit, lazyQuotes: c.LazyQuotes}
+	in.delimiter, in.sniffDelimiter, err = parseDelimiter(c.Delimiter)
+	if err != nil {
+		return configError{err}
+	}
+	if in.encoding, err = parseEncoding(c.Encoding); err != nil {
+		return configError{err}
+	}
+
+	if c.Report != "text" && c.Report != "json" {
+		return invalidf("unknown report format %q (must be text or json)", c.Report)
+	}
+
+	if c.Output != "db" && c.Output != "csv" && c.Output != "json" && c.Output != "parquet" {
+		return invalidf("unknown output %q (must be db, csv, json or parquet)", c.Output)
+	}
+
+	if c.RenameDuplicateProducts && c.Merge {
+		return invalidf("--rename-duplicate-products cannot be used with --merge which matches rows by kickstarter_id")
+	}
+
+	if c.ExportSchema != "" {
+		return exportSchema(os.Stdout, c.ExportSchema)
+	}
+
+	if c.Generate < 0 {
+		return invalidf("--generate must not be negative")
+	}
+	if c.Generate > 0 {
+		return writeSyntheticFile(c.Out, c.Generate)
+	}
+
+	if c.SchemaOut != "" {
+		tables := schemaTables
+		if c.RenameDuplicateProducts {
+			tables = allowDuplicateProducts(tables)
+		}
+		return writeDDLFile(c.SchemaOut, d, tables)
+	}
+
+	order := defaultLoadOrder()
+	if c.LoadOrder != "" {
+		o, err := parseLoadOrder(c.LoadOrder)
+		if err != nil {
+			return invalidf("parsing load order: %v", err)
+		}
+		order = o
+	}
+
+	filter := rowFilter{skipped: &skipCounts{}}
+	if c.States != "" {
+		filter.states = parseStateSet(c.States)
+	}
+	if c.Countries != "" {
+		filter.countries = parseCountrySet(c.Countries)
+	}
+	if c.From != "" {
+		if filter.from, err = parseRangeDate(c.From, false); err != nil {
+			return invalidf("parsing --from: %v", err)
+		}
+	}
+	if c.To != "" {
+		if filter.to, err = parseRangeDate(c.To, true); err != nil {
+			return invalidf("parsing --to: %v", err)
+		}
+	}
+	if !filter.from.IsZero() && !filter.to.IsZero() && !filter.from.Before(filter.to) {
+		return invalidf("--from must be before --to")
+	}
+	// Merging, upserting and renaming handle repeated kickstarter_ids
+	// themselves, see duplicates.go.
+	keepLast := false
+	switch c.DedupKeep {
+	case "", dedupFirst:
+		if !c.Merge && !c.Upsert && !c.RenameDuplicateProducts {
+			filter.seen = make(map[int64]bool)
+		}
+	case dedupLast:
+		if c.Stream || c.Output != "db" {
+			return invalidf("--dedup-keep last cannot be used with --stream or --output csv, json or parquet which write each row as it is read")
+		}
+		keepLast = !c.Merge && !c.Upsert && !c.RenameDuplicateProducts
+	default:
+		return invalidf("unknown --dedup-keep %q (must be first or last)", c.DedupKeep)
+	}
+
+	if c.Resume && (c.Output != "db" || c.Merge || c.RenameDuplicateProducts) {
+		return invalidf("--resume cannot be used with --output csv or json, --merge or --rename-duplicate-products")
+	}
+
+	if c.Upsert && (c.Output != "db" || c.Merge || c.Stream || c.Workers > 1 || c.Bulk || c.RenameDuplicateProducts || c.Resume) {
+		return invalidf("--upsert cannot be used with --output csv or json, --merge, --stream, --workers, --bulk, --rename-duplicate-products or --resume")
+	}
+
+	if c.Truncate && (c.Output != "db" || c.Merge || c.Append || c.Resume || c.Upsert) {
+		return invalidf("--truncate cannot be used with --output csv, json or parquet, --merge, --append, --resume or --upsert")
+	}
+
+	files, err := inputFiles(c.Input)
+	if err != nil {
+		return err
+	}
+	if files != nil && (c.Stream || c.Output != "db" && !c.DryRun && !c.CountOnly) {
+		return invalidf("an --input directory cannot be used with --stream or --output csv, json or parquet which read a single input")
+	}
+	if c.Backfill && files == nil {
+		return invalidf("--backfill needs an --input directory")
+	}
+	if c.Backfill && (c.Output != "db" || c.Merge || c.Upsert || c.Stream || c.Resume || c.Truncate || c.RenameDuplicateProducts || c.DryRun || c.CountOnly || keepLast) {
+		return invalidf("--backfill cannot be used with --output csv, json or parquet, --merge, --upsert, --stream, --resume, --truncate, --rename-duplicate-products, --dry-run, --count-only or --dedup-keep last")
+	}
+	if files != nil && !c.Backfill {
+		slog.Info("Reading input directory", "input", c.Input, "files", len(files), "extract_workers", c.ExtractWorkers)
+	}
+
+	var rates exchangeRates
+	if c.Rates != "" {
+		if rates, err = loadRates(c.Rates); err != nil {
+			return err
+		}
+		slog.Info("Loaded exchange rates", "currencies", len(rates))
+	}
+
+	file := c.Input
+	var sum string
+	if c.Checksum != "" && (!isFileInput(file) || files != nil) {
+		return invalidf("--checksum can only be used with an input file")
+	}
+	if c.Checksum != "" {
+		s, err := verifyChecksum(file, c.Checksum)
+		if err != nil {
+			return err
+		}
+		sum = s
+		slog.Info("Input checksum", "checksum", sum)
+	}
+
+	if c.DryRun {
+		return dryRun(ctx, c, in, files, filter, rates, keepLast, &sampleLoader{w: os.Stdout, samples: 5})
+	}
+	if c.CountOnly {
+		return dryRun(ctx, c, in, files, filter, rates, keepLast, &countLoader{w: os.Stdout})
+	}
+
+	slog.Debug("Connecting to database", "driver", d.driverName(), "datasource", d.maskDataSource(c.DataSource), "from", dataSourceFrom)
+	db, err := sql.Open(d.driverName(), c.DataSource)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	// The csv and json outputs do not use the database.
+	if c.Output == "db" || c.Delete || c.ValidateForeignKeys {
+		if err := pingDatabase(ctx, db); err != nil {
+			return err
+		}
+	}
+
+	if c.ValidateForeignKeys {
+		return validateForeignKeys(ctx, db, d, schemaTables)
+	}
+
+	if c.Delete {
+		if !c.Yes {
+			ok, err := confirm("--delete", "delete", "Delete all data from kickstarter table?")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Doing nothing")
+				return nil
+			}
+		}
+		slog.Info("Deleting all tables")
+		if err := deleteTables(ctx, db, d); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if c.Truncate {
+		if !c.Yes {
+			ok, err := confirm("--truncate", "truncate", "Truncate all kickstarter tables, keeping their schema?")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Println("Doing nothing")
+				return nil
+			}
+		}
+		tables := schemaTables
+		if c.RenameDuplicateProducts {
+			tables = allowDuplicateProducts(tables)
+		}
+		// The tables are created if they do not exist yet so that they can
+		// all be truncated.
+		if err := createTables(ctx, db, d, tables); err != nil {
+			return err
+		}
+		slog.Info("Truncating all tables")
+		if err := truncateTables(ctx, db, d); err != nil {
+			return err
+		}
+	}
+
+	if c.Output == "db" && !c.Merge && !c.Append && !c.Resume && !c.Upsert && !c.Truncate && !c.Backfill {
+		if c.Database == "" && d.kind != sqliteDialect {
+			name, err := d.databaseName(c.DataSource)
+			if err != nil {
+				return fmt.Errorf("parsing datasource: %v", err)
+			}
+			if name == "" {
+				return fmt.Errorf("the datasource does not name a database, use --database")
+			}
+			c.Database = name
+		}
+		count, err := countDatabaseTables(ctx, db, d, c.Database)
+		if err != nil {
+			return fmt.Errorf("counting database tables: %v", err)
+		}
+		if count != 0 {
+			slog.Warn("Database already has the tables of the ETL. Please delete them or run the program with --delete, --merge, --upsert, --append or --resume", "tables", count)
+			return nil
+		}
+	}
+
+	if c.Resume {
+		// The tables do not exist yet if no earlier load got as far.
+		if err := createTables(ctx, db, d, schemaTables); err != nil {
+			return err
+		}
+		loaded, err := loadedKickstarterIDs(ctx, db, d)
+		if err != nil {
+			return fmt.Errorf("reading loaded rows: %v", err)
+		}
+		id, rows, err := readCheckpoint(ctx, db, d)
+		if err != nil {
+			return fmt.Errorf("reading checkpoint: %v", err)
+		}
+		slog.Info("Resuming load", "loaded_rows", len(loaded), "checkpoint_kickstarter_id", id, "checkpoint_rows", rows)
+		filter.loaded = loaded
+	}
+
+	r, name, err := openInputs(ctx, c.Input, files, c.Timeout)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := handleInterrupts(cancel)
+	warns := newWarnings(c.WarnImmediately)
+	defer warns.printSummary(os.Stderr)
+	errs := &rowErrors{skip: c.SkipErrors, warns: warns}
+	defer errs.printReport(os.Stderr)
+
+	start := time.Now()
+	if c.Output != "db" {
+		newSink := func(w io.Writer) (sink, error) { return newCSVSink(w, c.QuoteAll) }
+		if c.Output == "json" {
+			newSink = func(w io.Writer) (sink, error) { return newJSONSink(w), nil }
+		}
+		if c.Output == "parquet" {
+			newSink = func(w io.Writer) (sink, error) { return newParquetSink(w), nil }
+		}
+		opts := sinkOptions{in: in, filter: filter, rates: rates, warns: warns, errs: errs, stop: stop}
+		if c.ValidateStates || c.Strict {
+			opts.allowedStates = parseStateSet(c.AllowedStates)
+			opts.strictStates = c.Strict
+		}
+		if err := writeFile(ctx, r, c.Out, newSink, opts); err != nil {
+			return err
+		}
+		if c.FailOnWarnings {
+			return warns.err()
+		}
+		return nil
+	}
+
+	meta, err := newLoadMetadata(file, sum, c.SourceVersion)
+	if err != nil {
+		return fmt.Errorf("describing load of %s: %v", file, err)
+	}
+	lookups := lookupOptions{maxValues: c.MaxLookupValues, fallback: c.LookupFallback, stats: newLookupStats()}
+
+	if c.Stream {
+		slog.Info("Creating tables")
+		if err := createTables(ctx, db, d, schemaTables); err != nil {
+			return err
+		}
+
+		slog.Info("Streaming data", "input", name)
+		opts := streamOptions{
+			dialect:   d,
+			order:     order,
+			batchSize: c.BatchSize,
+			in:        in,
+			limit:     newThrottle(c.TargetRPS),
+			stop:      stop,
+			warns:     warns,
+			errs:      errs,
+			filter:    filter,
+			rates:     rates,
+			report:    newLoadReport(),
+			meta:      &meta,
+			lookups:   lookups,
+		}
+		opts.report.lookups = lookups.stats
+		if c.ValidateStates || c.Strict {
+			opts.allowedStates = parseStateSet(c.AllowedStates)
+			opts.strictStates = c.Strict
+		}
+		_, err := streamData(ctx, db, r, opts)
+		if err == ErrInterrupted {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("streaming data: %v", err)
+		}
+
+		slog.Info("Finished ETL", "duration", time.Since(start))
+		opts.report.finish(errs, filter.skipped, time.Since(start))
+		if err := opts.report.write(c.Report, os.Stdout); err != nil {
+			return err
+		}
+		if c.FailOnWarnings {
+			return warns.err()
+		}
+		return nil
+	}
+
+	transformer := &KickstartTransformer{warns: warns, filter: filter, rates: rates, renameDuplicates: c.RenameDuplicateProducts, dedupLast: keepLast}
+	if c.ValidateStates || c.Strict {
+		transformer.allowedStates = parseStateSet(c.AllowedStates)
+		transformer.strictStates = c.Strict
+	}
+	loader := &SQLLoader{
+		db:                 db,
+		dialect:            d,
+		tables:             schemaTables,
+		order:              order,
+		batchSize:          c.BatchSize,
+		dimensionBatchSize: c.DimensionBatchSize,
+		lookups:            lookups,
+		workers:            c.Workers,
+		maxRetries:         c.MaxRetries,
+		bulk:               c.Bulk,
+		merge:              c.Merge,
+		upsert:             c.Upsert,
+		limit:              newThrottle(c.TargetRPS),
+		stop:               stop,
+		meta:               &meta,
+	}
+	if c.RenameDuplicateProducts {
+		loader.tables = allowDuplicateProducts(loader.tables)
+	}
+
+	report := newLoadReport()
+	report.lookups = lookups.stats
+	var times stageTimes
+	if c.Backfill {
+		b := &backfill{
+			db:            db,
+			dialect:       d,
+			files:         files,
+			in:            in,
+			timeout:       c.Timeout,
+			sourceVersion: c.SourceVersion,
+			transformer:   transformer,
+			loader:        loader,
+			report:        report,
+			warns:         warns,
+			errs:          errs,
+			stop:          stop,
+		}
+		if times, err = b.run(ctx); err != nil {
+			return err
+		}
+	} else {
+		slog.Info("Extracting data", "input", name)
+		if _, times, err = runStages(ctx, r, inputExtractor(in, files, c.ExtractWorkers, c.Timeout, warns, errs), transformer, reportingLoader{loader, report}); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Finished ETL", "duration", time.Since(start), "extract", times.Extract, "transform", times.Transform, "load", times.Load)
+	report.times = times
+	report.finish(errs, filter.skipped, time.Since(start))
+	if err := report.write(c.Report, os.Stdout); err != nil {
+		return err
+	}
+
+	if c.FailOnWarnings {
+		return warns.err()
+	}
+	return nil
+}
+
+// confirm asks question on stdin before the action of flag is done. It fails
+// instead of waiting for an answer that will never come if stdin is not a
+// terminal.
+func confirm(flag, action, question string) (bool, error) {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false, err
+	}
+	if fi.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("%s needs confirmation but stdin is not a terminal, use --yes to %s without asking", flag, action)
+	}
+	fmt.Printf("%s (y/n) ", question)
+	r := bufio.NewReader(os.Stdin)
+	answer, err := r.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(string(answer))
+	return strings.Contains(answer, "y"), nil
+}
+
+// dryRun extracts and transforms the input like Run but hands the kickstarts
+// to l, which prints something about them instead of loading them. It does
+// not touch the database.
+func dryRun(ctx context.Context, c Config, in inputOptions, files []string, filter rowFilter, rates exchangeRates, keepLast bool, l Loader) error {
+	r, name, err := openInputs(ctx, c.Input, files, c.Timeout)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// A dry run writes nothing that is worth finishing, so an interrupt
+	// stops it right away.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := handleInterrupts(cancel)
+	stop.cancelOnStop(ctx, cancel)
+	warns := newWarnings(c.WarnImmediately)
+	defer warns.printSummary(os.Stderr)
+	errs := &rowErrors{skip: c.SkipErrors, warns: warns}
+	defer errs.printReport(os.Stderr)
+
+	transformer := &KickstartTransformer{warns: warns, filter: filter, rates: rates, renameDuplicates: c.RenameDuplicateProducts, dedupLast: keepLast}
+	if c.ValidateStates || c.Strict {
+		transformer.allowedStates = parseStateSet(c.AllowedStates)
+		transformer.strictStates = c.Strict
+	}
+
+	slog.Info("Extracting data", "input", name)
+	extractor := inputExtractor(in, files, c.ExtractWorkers, c.Timeout, warns, errs)
+	if _, _, err := runStages(ctx, r, extractor, transformer, l); err != nil {
+		if stop.stopRequested() {
+			return ErrInterrupted
+		}
+		return err
+	}
+	if c.FailOnWarnings {
+		return warns.err()
+	}
+	return nil
+}
+
+type Data struct {
+	ID             int64
+	Name           string
+	Category       string
+	MainCategory   string
+	Currency       string
+	Deadline       time.Time
+	Launched       time.Time
+	State          string
+	Country        string
+	Backers        int
+	Pledged        float64
+	PledgedUSD     sql.NullFloat64
+	PledgedUSDReal sql.NullFloat64
+	Goal           float64
+	GoalUSDReal    sql.NullFloat64
+}
+
+// Extract reads the raw kickstarter CSV from r.
+func Extract(ctx context.Context, r io.Reader) ([]Data, error) {
+	return (&CSVExtractor{}).Extract(ctx, r)
+}
+
+func extractData(ctx context.Context, r io.Reader, in inputOptions, warns *warnings, errs *rowErrors) ([]Data, error) {
+	var dd []Data
+	err := eachRow(ctx, r, in, warns, errs, func(d Data) error {
+		dd = append(dd, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dd, nil
+}
+
+// eachRow reads the raw kickstarter data from r in the format of in and calls
+// fn with each row once it has been parsed. Errors about a row include the
+// line of the input it was read from. It stops at the first error returned by
+// fn or, if in.maxRows is positive, after reading that many data rows.
+func eachRow(ctx context.Context, r io.Reader, in inputOptions, warns *warnings, errs *rowErrors, fn func(d Data) error) error {
+	if in.format == xlsxInput {
+		// A workbook is a zip archive whose text is always UTF-8.
+		return eachXLSXRow(ctx, r, in.maxRows, errs, fn)
+	}
+	br := skipBOM(decode(r, in.encoding))
+	if b, err := br.Peek(len(zipMagic)); err == nil && bytes.Equal(b, zipMagic) {
+		return fmt.Errorf("the input is a zip archive, such as an Excel workbook which needs --input-format xlsx")
+	}
+	r = br
+	if in.format == jsonLinesInput {
+		return eachJSONLine(ctx, r, in.maxRows, errs, fn)
+	}
+	return eachCSVRow(ctx, r, in, errs, fn)
+}
+
+// eachCSVRow is eachRow for the kickstarter CSV.
+func eachCSVRow(ctx context.Context, r io.Reader, in inputOptions, errs *rowErrors, fn func(d Data) error) error {
+	delimiter := in.delimiter
+	if in.sniffDelimiter {
+		br := bufio.NewReader(r)
+		d, err := sniffDelimiter(br)
+		if err != nil {
+			return err
+		}
+		slog.Debug("Detected delimiter", "delimiter", string(d))
+		delimiter, r = d, br
+	}
+	csvr := csv.NewReader(r)
+	if delimiter != 0 {
+		csvr.Comma = delimiter
+	}
+	// The number of fields is checked below so that a short row is reported
+	// like any other malformed row.
+	csvr.FieldsPerRecord = -1
+	csvr.LazyQuotes = in.lazyQuotes
+	header, err := csvr.Read()
+	if err != nil {
+		return err
+	}
+	idx, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+	for n := 0; in.maxRows <= 0 || n < in.maxRows; n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row, err := csvr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		validUTF8(row)
+		errs.rows++
+		var d Data
+		if len(row) != len(header) {
+			err = &rowError{Column: "row", Value: truncate(strings.Join(row, string(csvr.Comma)), 40), Err: fmt.Errorf("has %d fields, want %d", len(row), len(header))}
+		} else {
+			d, err = parseRow(row, idx)
+		}
+		if rerr, ok := err.(*rowError); ok {
+			rerr.Line, _ = csvr.FieldPos(rerr.Field)
+		}
+		if err != nil {
+			if err := errs.add(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undefinedCountry is the country of the rows of the dataset whose country is
+// not known. It is loaded as unknownCountry instead.
+const (
+	undefinedCountry = `N,0"`
+	unknownCountry   = "Unknown"
+)
+
+// csvColumns are the columns of the kickstarter CSV that are read, by their
+// names in its header. They may appear in any order and other columns are
+// ignored.
+var csvColumns = []string{
+	"ID",
+	"name",
+	"category",
+	"main_category",
+	"currency",
+	"deadline",
+	"goal",
+	"launched",
+	"pledged",
+	"state",
+	"backers",
+	"country",
+	"usd pledged",
+}
+
+// optionalColumns are columns of csvColumns that may be missing from the
+// header. The 2016 edition of the dataset (ks-projects-201612.csv) has no
+// usd_pledged_real and usd_goal_real, so the pledged_usd_real, goal_usd_real
+// and funding_pct of its rows are NULL.
+var optionalColumns = []string{
+	"usd_pledged_real",
+	"usd_goal_real",
+}
+
+// columnIndex maps the names of the columns of a CSV header to their index.
+type columnIndex map[string]int
+
+// parseHeader returns the index of the columns of header. It is an error if
+// one of csvColumns is missing or appears twice. The names in the header are
+// trimmed because the 2016 edition pads them with spaces.
+func parseHeader(header []string) (columnIndex, error) {
+	idx := make(columnIndex)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if _, ok := idx[name]; ok && name != "" {
+			return nil, fmt.Errorf("header has column %q more than once", name)
+		}
+		idx[name] = i
+	}
+	var missing []string
+	for _, c := range csvColumns {
+		if _, ok := idx[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("header is missing the columns %s", strings.Join(missing, ", "))
+	}
+	for _, c := range optionalColumns {
+		if _, ok := idx[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) != 0 {
+		slog.Info("Input is missing optional columns, loading them as NULL", "columns", strings.Join(missing, ","))
+	}
+	return idx, nil
+}
+
+// Layouts of the dates in the kickstarter dataset.
+const (
+	deadlineLayout = "2006-01-02"
+	launchedLayout = "2006-01-02 15:04:05"
+)
+
+// parseRow parses a single CSV row into Data, finding its fields with idx.
+// The errors it returns do not know the line of the row, eachRow fills it in.
+func parseRow(row []string, idx columnIndex) (Data, error) {
+	d := Data{
+		Name:         row[idx["name"]],
+		Category:     row[idx["category"]],
+		MainCategory: row[idx["main_category"]],
+		Currency:     row[idx["currency"]],
+		State:        row[idx["state"]],
+		Country:      row[idx["country"]],
+	}
+
+	// field returns the index and value of the named column.
+	field := func(name string) (int, string) {
+		i := idx[name]
+		return i, row[i]
+	}
+	// nullFloat parses the named optional column and leaves it NULL if it is
+	// missing from the header.
+	nullFloat := func(name, column string) (sql.NullFloat64, error) {
+		i, ok := idx[name]
+		if !ok {
+			return sql.NullFloat64{}, nil
+		}
+		f, err := strconv.ParseFloat(row[i], 64)
+		if err != nil {
+			return sql.NullFloat64{}, &rowError{Field: i, Column: column, Value: row[i], Err: err}
+		}
+		return sql.NullFloat64{Float64: f, Valid: true}, nil
+	}
+
+	i, v := field("deadline")
+	deadline, err := time.Parse(deadlineLayout, v)
+	if err != nil {
+		// The 2016 edition has the time of the deadline too.
+		deadline, err = time.Parse(launchedLayout, v)
+	}
+	if err != nil {
+		return Data{}, &rowError{Field: i, Column: "deadline", Value: v, Err: err}
+	}
+	d.Deadline = deadline
+
+	i, v = field("launched")
+	launched, err := time.Parse(launchedLayout, v)
+	if err != nil {
+		return Data{}, &rowError{Field: i, Column: "launched", Value: v, Err: err}
+	}
+	d.Launched = launched
+
+	i, v = field("ID")
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return Data{}, &rowError{Field: i, Column: "id", Value: v, Err: err}
+	}
+	d.ID = id
+
+	i, v = field("goal")
+	goal, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return Data{}, &rowError{Field: i, Column: "goal", Value: v, Err: err}
+	}
+	d.Goal = goal
+
+	i, v = field("pledged")
+	pledged, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return Data{}, &rowError{Field: i, Column: "pledged", Value: v, Err: err}
+	}
+	d.Pledged = pledged
+
+	i, v = field("backers")
+	backers, err := strconv.Atoi(v)
+	if err != nil {
+		return Data{}, &rowError{Field: i, Column: "backers", Value: v, Err: err}
+	}
+	d.Backers = backers
+
+	// The usd pledged is missing from thousands of rows that are otherwise
+	// valid, mostly those of an undefined country. They are kept with a NULL
+	// usd pledged.
+	if i, v = field("usd pledged"); v != "" {
+		pledgedUSD, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return Data{}, &rowError{Field: i, Column: "pledgedUSD", Value: v, Err: err}
+		}
+		d.PledgedUSD = sql.NullFloat64{Float64: pledgedUSD, Valid: true}
+	}
+
+	if d.PledgedUSDReal, err = nullFloat("usd_pledged_real", "pledgedUSDReal"); err != nil {
+		return Data{}, err
+	}
+	if d.GoalUSDReal, err = nullFloat("usd_goal_real", "goalUSDReal"); err != nil {
+		return Data{}, err
+	}
+
+	return d, nil
+}
+
+// Transform turns the extracted rows into kickstarts ready to be loaded.
+func Transform(ctx context.Context, dd []Data) ([]Kickstart, error) {
+	return (&KickstartTransformer{}).Transform(ctx, dd)
+}
+
+// transformData transforms dd into kickstarts and drops those that do not pass
+// filter.
+func transformData(ctx context.Context, dd []Data, filter rowFilter, warns *warnings) ([]Kickstart, error) {
+	if filter.skipped == nil {
+		filter.skipped = &skipCounts{}
+	}
+	var kk []Kickstart
+	for i, d := range dd {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		k := transformRow(int64(i+1), d, warns)
+		if !filter.keep(k, warns) {
+			continue
+		}
+		kk = append(kk, k)
+	}
+	filter.skipped.log(len(kk))
+	return kk, nil
+}
+
+// transformRow transforms d into a Kickstart whose related entities all use
+// the given id.
+func transformRow(id int64, d Data, warns *warnings) Kickstart {
+	product := Product{ID: id, KickstarterID: d.ID, Name: normalizeSpace(d.Name)}
+	mainCategory := MainCategory{ID: id, Name: normalizeSpace(d.MainCategory)}
+	category := Category{ID: id, Name: normalizeSpace(d.Category)}
+	currency := Currency{ID: id, Type: d.Currency}
+	date := Date{ID: id, Launched: d.Launched, Deadline: d.Deadline}
+	state := State{ID: id, State: d.State}
+	area := Area{ID: id, Country: normalizeSpace(d.Country)}
+	if area.Country == undefinedCountry {
+		area.Country = unknownCountry
+	}
+
+	duration := durationDays(d.Launched, d.Deadline)
+	if duration <= 0 {
+		warns.warn("non-positive duration", "id %d runs for %d days", d.ID, duration)
+	}
+	if !d.PledgedUSD.Valid {
+		warns.warn("no usd pledged", "id %d has no usd pledged, it is loaded as NULL", d.ID)
+	}
+
+	return Kickstart{
+		Product:      product,
+		MainCategory: mainCategory,
+		Category:     category,
+		Currency:     currency,
+		Date:         date,
+		State:        state,
+		Area:         area,
+
+		ProductID:      id,
+		MainCategoryID: id,
+		CategoryID:     id,
+		CurrencyID:     id,
+		DateID:         id,
+		StateID:        id,
+		AreaID:         id,
+
+		Backers:        d.Backers,
+		Goal:           d.Goal,
+		GoalUSDReal:    d.GoalUSDReal,
+		Pledged:        d.Pledged,
+		PledgedUSD:     d.PledgedUSD,
+		PledgedUSDReal: d.PledgedUSDReal,
+		DurationDays:   duration,
+		FundingPct:     fundingPct(d.PledgedUSDReal, d.GoalUSDReal),
+	}
+}
+
+// normalizeSpace trims s and collapses every run of whitespace inside it, such
+// as stray tabs, to a single space so that " Music " and "Music" end up as the
+// same lookup value.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// fundingPct returns pledged as a percentage of goal. It is NULL when the goal
+// is zero.
+func fundingPct(pledged, goal sql.NullFloat64) sql.NullFloat64 {
+	if !pledged.Valid || !goal.Valid || goal.Float64 == 0 {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: pledged.Float64 / goal.Float64 * 100, Valid: true}
+}
+
+// durationDays returns the number of days from launched to deadline rounded
+// to whole days.
+func durationDays(launched, deadline time.Time) int {
+	return int(math.Round(deadline.Sub(launched).Hours() / 24))
+}
+
+type Kickstart struct {
+	Product      Product
+	MainCategory MainCategory
+	Category     Category
+	Currency     Currency
+	Date         Date
+	State        State
+	Area         Area
+
+	ProductID      int64
+	MainCategoryID int64
+	CategoryID     int64
+	CurrencyID     int64
+	DateID         int64
+	StateID        int64
+	AreaID         int64
+
+	Backers        int
+	Goal           float64
+	GoalUSDReal    sql.NullFloat64
+	Pledged        float64
+	PledgedUSD     sql.NullFloat64
+	PledgedUSDReal sql.NullFloat64
+	DurationDays   int
+	FundingPct     sql.NullFloat64
+
+	// GoalReporting and PledgedReporting are the goal and pledged in the
+	// reporting currency of the exchange rates given with --rates. They are
+	// NULL without rates or if the currency has no rate.
+	GoalReporting    sql.NullFloat64
+	PledgedReporting sql.NullFloat64
+}
+
+type Product struct {
+	ID            int64
+	KickstarterID int64
+	Name          string
+}
+
+type MainCategory struct {
+	ID   int64
+	Name string
+}
+
+type Category struct {
+	ID   int64
+	Name string
+}
+
+type Currency struct {
+	ID   int64
+	Type string
+}
+
+type Date struct {
+	ID       int64
+	Launched time.Time
+	Deadline time.Time
+}
+
+type State struct {
+	ID    int64
+	State string
+}
+
+type Area struct {
+	ID      int64
+	Country string
+}
+
+func createTables(ctx context.Context, db *sql.DB, d dialect, tables []table) error {
+	for _, t := range withETLTables(tables) {
+		if _, err := db.ExecContext(ctx, t.createStatement(d)); err != nil {
+			return fmt.Errorf("creating table %s: %v", t.Name, err)
+		}
+		for _, stmt := range t.indexStatements(d) {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("creating indexes of table %s: %v", t.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteTables drops the tables createTables creates, with the prefix of d.
+// They are dropped in the reverse of the order they are created in so that
+// the kickstarts are dropped before the dimensions they reference.
+func deleteTables(ctx context.Context, db *sql.DB, d dialect) error {
+	tables := withETLTables(schemaTables)
+	for i := len(tables) - 1; i >= 0; i-- {
+		name := d.table(tables[i].Name)
+		if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS "+name); err != nil {
+			return fmt.Errorf("dropping table %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// truncateTables deletes all rows of the tables createTables creates but
+// keeps the tables themselves, along with their indexes and grants. The ids
+// start from 1 again. load_metadata is left alone as it is the history of the
+// loads, the truncated ones included. MySQL commits each TRUNCATE on its own,
+// so its foreign key checks are turned off instead of truncating the tables in
+// a transaction. PostgreSQL truncates all of them in a single statement and
+// SQLite, which has no TRUNCATE, deletes their rows and their AUTOINCREMENT
+// counters in sqlite_sequence in a transaction.
+func truncateTables(ctx context.Context, db *sql.DB, d dialect) error {
+	tables := append(append([]table(nil), schemaTables...), checkpointTable, backfillTable)
+	var names []string
+	for i := len(tables) - 1; i >= 0; i-- {
+		names = append(names, d.table(tables[i].Name))
+	}
+	switch d.kind {
+	case postgresDialect:
+		if _, err := db.ExecContext(ctx, "TRUNCATE TABLE "+strings.Join(names, ", ")+" RESTART IDENTITY"); err != nil {
+			return fmt.Errorf("truncating tables: %v", err)
+		}
+		return nil
+	case sqliteDialect:
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, name := range names {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+name); err != nil {
+				return fmt.Errorf("truncating table %s: %v", name, err)
+			}
+		}
+		query := "DELETE FROM sqlite_sequence WHERE name IN " + questionPlaceholders.placeholders(len(names), 1)
+		args := make([]interface{}, len(names))
+		for i, name := range names {
+			args[i] = name
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("resetting ids: %v", err)
+		}
+		return tx.Commit()
+	default:
+		// FOREIGN_KEY_CHECKS is a session variable so every statement must
+		// run on the same connection.
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+			return err
+		}
+		for _, name := range names {
+			if _, err = conn.ExecContext(ctx, "TRUNCATE TABLE "+name); err != nil {
+				err = fmt.Errorf("truncating table %s: %v", name, err)
+				break
+			}
+		}
+		// The connection goes back to the pool so the checks are turned
+		// back on even if a TRUNCATE failed.
+		if _, serr := conn.ExecContext(context.Background(), "SET FOREIGN_KEY_CHECKS = 1"); err == nil {
+			err = serr
+		}
+		return err
+	}
+}
+
+// countDatabaseTables returns how many of the tables managed by the ETL exist
+// in the named database. Other tables of the database are not counted.
+func countDatabaseTables(ctx context.Context, db *sql.DB, d dialect, database string) (int, error) {
+	var tables []string
+	for _, t := range withETLTables(schemaTables) {
+		tables = append(tables, d.table(t.Name))
+	}
+	query, args := d.countTablesQuery(database, tables)
+	var count int
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx so that rows can be
+// inserted inside or outside of a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Load creates the tables of the star schema in the MySQL database db if they
+// do not exist and loads kk into them.
+func Load(ctx context.Context, db *sql.DB, kk []Kickstart) error {
+	return NewSQLLoader(db).Load(ctx, kk)
+}
+
+// loadData loads kk in a single transaction so that a failed load leaves the
+// database untouched. If a stop is requested, the rows loaded so far are
+// committed before returning ErrInterrupted. If ctx is cancelled the
+// transaction is rolled back instead. The progress of the load is reported to
+// progress. If bulk is true, the fact rows are inserted with a single MySQL LOAD
+// DATA LOCAL INFILE statement, see bulkLoad.
+//
+// The load takes two passes: the distinct lookup values are inserted first,
+// dimensionBatchSize values per statement, and then the fact rows, batchSize
+// rows per statement, along with their products and dates.
+func loadData(ctx context.Context, db *sql.DB, d dialect, kk []Kickstart, order []string, batchSize, dimensionBatchSize int, lookups lookupOptions, bulk bool, meta *loadMetadata, limit *throttle, stop *shutdown, progress ProgressFunc) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cache, err := newLoadedLookupCache(ctx, tx, d, lookups)
+	if err != nil {
+		return err
+	}
+	if err := cache.insertLookupValues(ctx, tx, d, kk, dimensionBatchSize); err != nil {
+		return err
+	}
+	var batch factWriter = newFactBatch(tx, d, batchSize)
+	if bulk {
+		b, err := newBulkLoad(tx, d)
+		if err != nil {
+			return err
+		}
+		defer b.close()
+		batch = b
+	}
+	for i, k := range kk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stop.stopRequested() {
+			if err := batch.flush(ctx); err != nil {
+				return err
+			}
+			if i > 0 {
+				if err := saveCheckpoint(ctx, tx, d, kk[i-1], i); err != nil {
+					return fmt.Errorf("saving checkpoint: %v", err)
+				}
+			}
+			if err := recordLoad(ctx, tx, d, meta, i); err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			slog.Info("Stopped loading", "rows", i, "total", len(kk))
+			return ErrInterrupted
+		}
+		if i%progressInterval == 0 {
+			progress(i, len(kk))
+		}
+		limit.wait()
+
+		ids, err := insertDimensions(ctx, tx, d, k, order, cache)
+		if err != nil {
+			return err
+		}
+		if err := batch.add(ctx, k, ids); err != nil {
+			return err
+		}
+	}
+	if err := batch.flush(ctx); err != nil {
+		return err
+	}
+	if len(kk) > 0 {
+		if err := saveCheckpoint(ctx, tx, d, kk[len(kk)-1], len(kk)); err != nil {
+			return fmt.Errorf("saving checkpoint: %v", err)
+		}
+	}
+	if err := recordLoad(ctx, tx, d, meta, len(kk)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	progress(len(kk), len(kk))
+	slog.Info("Loaded data", "rows", len(kk))
+	cache.logCardinality()
+	return nil
+}
+
+// insertKickstart inserts the rows of k into each table in order. The order
+// must respect foreign key dependencies as the fact row needs the ids of the
+// dimension rows.
+func insertKickstart(ctx context.Context, db execer, d dialect, k Kickstart, order []string, cache *lookupCache) error {
+	ids, err := insertDimensions(ctx, db, d, k, order, cache)
+	if err != nil {
+		return err
+	}
+	if _, err := insertRow(ctx, db, d, "kickstarts", k, ids); err != nil {
+		return fmt.Errorf("inserting into kickstarts: %w", err)
+	}
+	return nil
+}
+
+// insertDimensions inserts the dimension rows of k into each table in order,
+// skipping the kickstarts fact table, and returns their ids by table name. The
+// rows of lookup tables are only inserted if their value is new. The order
+// must respect foreign key dependencies.
+func insertDimensions(ctx context.Context, db execer, d dialect, k Kickstart, order []string, cache *lookupCache) (map[string]int64, error) {
+	ids := make(map[string]int64)
+	for _, table := range order {
+		if table == "kickstarts" {
+			continue
+		}
+		var (
+			id  int64
+			err error
+		)
+		if lt, ok := findLookupTable(table); ok {
+			id, err = cache.getOrInsert(ctx, db, d, lt, lt.values(k, ids)...)
+		} else {
+			id, err = insertRow(ctx, db, d, table, k, ids)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("inserting into %s: %w", table, err)
+		}
+		ids[table] = id
+	}
+	return ids, nil
+}
+
+func insertRow(ctx context.Context, db execer, d dialect, table string, k Kickstart, ids map[string]int64) (int64, error) {
+	switch table {
+	case "products":
+		return d.insertID(ctx, db, d.insert("products", 1, "kickstarter_id", "name"), k.Product.KickstarterID, k.Product.Name)
+	case "dates":
+		return d.insertID(ctx, db, d.insert("dates", 1, "deadline", "launched"), d.timeArg(k.Date.Deadline), d.timeArg(k.Date.Launched))
+	case "kickstarts":
+		return d.insertID(ctx, db, d.insert("kickstarts", 1, kickstartColumns...), kickstartArgs(k, ids)...)
+	default:
+		return 0, fmt.Errorf("unknown table")
+	}
+}
+
+// kickstartColumns are the columns of a kickstarts fact row in the order of
+// the values returned by kickstartArgs.
+var kickstartColumns = []string{
+	"product_id",
+	"main_category_id",
+	"category_id",
+	"currency_id",
+	"date_id",
+	"state_id",
+	"area_id",
+	"goal",
+	"goal_usd_real",
+	"backers",
+	"pledged",
+	"pledged_usd",
+	"pledged_usd_real",
+	"duration_days",
+	"funding_pct",
+	"goal_reporting",
+	"pledged_reporting",
+	"row_hash",
+}
+
+// kickstartArgs returns the values of the fact row of k given the ids of its
+// dimension rows by table name.
+func kickstartArgs(k Kickstart, ids map[string]int64) []interface{} {
+	return []interface{}{
+		ids["products"],
+		ids["main_categories"],
+		ids["categories"],
+		ids["currencies"],
+		ids["dates"],
+		ids["states"],
+		ids["areas"],
+		k.Goal,
+		k.GoalUSDReal,
+		k.Backers,
+		k.Pledged,
+		k.PledgedUSD,
+		k.PledgedUSDReal,
+		k.DurationDays,
+		k.FundingPct,
+		k.GoalReporting,
+		k.PledgedReporting,
+		k.rowHash(),
+	}
+}
@@ -0,0 +1,417 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestRunInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Config)
+	}{
+		{"unknown driver", func(c *Config) { c.Driver = "oracle" }},
+		{"batch size", func(c *Config) { c.BatchSize = 0 }},
+		{"workers", func(c *Config) { c.Workers = 0 }},
+		{"workers with sqlite", func(c *Config) { c.Workers = 2 }},
+		{"delimiter", func(c *Config) { c.Delimiter = "ab" }},
+		{"encoding", func(c *Config) { c.Encoding = "ebcdic" }},
+		{"output", func(c *Config) { c.Output = "xml" }},
+		{"from after to", func(c *Config) { c.From, c.To = "2018-01-01", "2017-01-01" }},
+		{"dedup keep", func(c *Config) { c.DedupKeep = "middle" }},
+		{"resume with csv", func(c *Config) { c.Resume = true }},
+		{"truncate with csv", func(c *Config) { c.Truncate = true }},
+		{"extract workers", func(c *Config) { c.ExtractWorkers = -1 }},
+		{"directory with csv", func(c *Config) { c.Input = filepath.Dir(c.Input) }},
+		{"backfill of a file", func(c *Config) { c.Output, c.Backfill = "db", true }},
+		{"backfill with csv", func(c *Config) { c.Input, c.Backfill = filepath.Dir(c.Input), true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig(writeTestFile(t, "in.csv", testCSV(testRow(nil))), filepath.Join(t.TempDir(), "out.csv"))
+			tt.change(&c)
+			if err := Run(context.Background(), c); !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("Run returned %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestRunErrorIsNotInvalidConfig(t *testing.T) {
+	c := testConfig(filepath.Join(t.TempDir(), "missing.csv"), filepath.Join(t.TempDir(), "out.csv"))
+	if err := Run(context.Background(), c); err == nil || errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Run returned %v, want an error other than ErrInvalidConfig", err)
+	}
+}
+
+func TestRunConfirm(t *testing.T) {
+	// Run asks before deleting unless stdin is not a terminal, as a file is
+	// not, in which case it fails with the flag that needs confirmation.
+	stdin := os.Stdin
+	t.Cleanup(func() { os.Stdin = stdin })
+	f, err := os.Open(writeTestFile(t, "answer", "y\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Stdin = f
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   string
+	}{
+		{"delete", func(c *Config) { c.Delete = true }, "--delete needs confirmation but stdin is not a terminal, use --yes to delete without asking"},
+		{"truncate", func(c *Config) { c.Truncate = true }, "--truncate needs confirmation but stdin is not a terminal, use --yes to truncate without asking"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(testRow(nil))))
+			tt.change(&c)
+			if err := Run(context.Background(), c); err == nil || err.Error() != tt.want {
+				t.Fatalf("Run returned %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPledgedUSDReal(t *testing.T) {
+	row := testRow(map[string]string{"pledged": "2000.00", "usd pledged": "1500.00", "usd_pledged_real": "1234.56"})
+	kk := testKickstarts(t, row)
+	if got := kk[0].PledgedUSDReal; !got.Valid || got.Float64 != 1234.56 {
+		t.Errorf("PledgedUSDReal is %v, want 1234.56", got)
+	}
+
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(row)))
+	if err := Run(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	var got float64
+	if err := db.QueryRow("SELECT pledged_usd_real FROM kickstarts").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != 1234.56 {
+		t.Errorf("loaded pledged_usd_real %v, want 1234.56", got)
+	}
+}
+
+func TestExtractPledgedUSD(t *testing.T) {
+	warns := newWarnings(false)
+	dd, err := extractData(context.Background(), strings.NewReader(testCSV(testRow(map[string]string{"usd pledged": ""}))), inputOptions{}, warns, &rowErrors{warns: warns})
+	if err != nil {
+		t.Fatalf("extracting an empty usd pledged: %v", err)
+	}
+	if len(dd) != 1 || dd[0].PledgedUSD.Valid {
+		t.Errorf("extracted %+v, want one row with a NULL usd pledged", dd)
+	}
+
+	in := testCSV(testRow(map[string]string{"ID": "1"}), testRow(map[string]string{"ID": "2", "usd pledged": "1.2.3"}))
+	_, err = extractData(context.Background(), strings.NewReader(in), inputOptions{}, warns, &rowErrors{warns: warns})
+	if err == nil || !strings.Contains(err.Error(), `line 3`) || !strings.Contains(err.Error(), `"1.2.3"`) {
+		t.Errorf("extracting a garbage usd pledged returned %v, want an error of line 3", err)
+	}
+
+	dd, err = extractData(context.Background(), strings.NewReader(in), inputOptions{}, warns, &rowErrors{skip: true, warns: warns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dd) != 1 || dd[0].ID != 1 {
+		t.Errorf("extracted %+v, want the garbage row skipped", dd)
+	}
+}
+
+func TestLoadNormalizesSpace(t *testing.T) {
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(
+		testRow(map[string]string{"ID": "1", "category": "Music", "main_category": "Film & Video"}),
+		testRow(map[string]string{"ID": "2", "category": " Music ", "main_category": "Film \t&  Video"}),
+	)))
+	if err := Run(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM categories"); n != 1 {
+		t.Errorf("categories has %d rows, want \" Music \" to be the row of \"Music\"", n)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM main_categories"); n != 1 {
+		t.Errorf("main_categories has %d rows, want \"Film \\t&  Video\" to be the row of \"Film & Video\"", n)
+	}
+}
+
+func TestExtractLazyQuotes(t *testing.T) {
+	in := testCSV(testRow(map[string]string{"name": `The 6" Figure`}))
+	warns := newWarnings(false)
+	_, err := extractData(context.Background(), strings.NewReader(in), inputOptions{}, warns, &rowErrors{warns: warns})
+	if err == nil || !strings.Contains(err.Error(), `bare "`) {
+		t.Errorf("extracting a bare quote returned %v, want a bare quote error", err)
+	}
+	dd, err := extractData(context.Background(), strings.NewReader(in), inputOptions{lazyQuotes: true}, warns, &rowErrors{warns: warns})
+	if err != nil {
+		t.Fatalf("extracting a bare quote with lazy quotes: %v", err)
+	}
+	if len(dd) != 1 || dd[0].Name != `The 6" Figure` {
+		t.Errorf("extracted %+v, want the name The 6\" Figure", dd)
+	}
+}
+
+// testData returns the Data of the row of testFields with the given changes.
+func testData(change func(d *Data)) Data {
+	d := Data{
+		ID:             1000003930,
+		Name:           "Greeting From Earth: ZGAC Arts Capsule For ET",
+		Category:       "Narrative Film",
+		MainCategory:   "Film & Video",
+		Currency:       "USD",
+		Deadline:       time.Date(2017, 11, 1, 0, 0, 0, 0, time.UTC),
+		Launched:       time.Date(2017, 9, 2, 4, 43, 57, 0, time.UTC),
+		State:          "failed",
+		Country:        "US",
+		Backers:        15,
+		Pledged:        2421,
+		PledgedUSD:     sql.NullFloat64{Float64: 100, Valid: true},
+		PledgedUSDReal: sql.NullFloat64{Float64: 2421, Valid: true},
+		Goal:           30000,
+		GoalUSDReal:    sql.NullFloat64{Float64: 30000, Valid: true},
+	}
+	if change != nil {
+		change(&d)
+	}
+	return d
+}
+
+func TestExtractData(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		opts    inputOptions
+		want    []Data
+		wantErr string
+	}{
+		{"clean row", testCSV(testRow(nil)), inputOptions{}, []Data{testData(nil)}, ""},
+		{"empty usd pledged", testCSV(testRow(map[string]string{"usd pledged": ""})), inputOptions{},
+			[]Data{testData(func(d *Data) { d.PledgedUSD = sql.NullFloat64{} })}, ""},
+		{"garbage goal", testCSV(testRow(map[string]string{"goal": "abc"})), inputOptions{}, nil, `line 2: parsing goal "abc"`},
+		{"short row", testCSV("1,short"), inputOptions{}, nil, `line 2: parsing row "1,short": has 2 fields, want 15`},
+		{"bom", utf8BOM + testCSV(testRow(nil)), inputOptions{}, []Data{testData(nil)}, ""},
+		{"invalid utf-8", testCSV(testRow(map[string]string{"name": "Caf\xe9"})), inputOptions{},
+			[]Data{testData(func(d *Data) { d.Name = "Caf\uFFFD" })}, ""},
+		{"latin1", testCSV(testRow(map[string]string{"name": "Caf\xe9"})), inputOptions{encoding: charmap.ISO8859_1},
+			[]Data{testData(func(d *Data) { d.Name = "Caf\u00e9" })}, ""},
+		{"max rows", testCSV(testRow(map[string]string{"ID": "1"}), testRow(map[string]string{"ID": "2"}), testRow(map[string]string{"ID": "3"})), inputOptions{maxRows: 2},
+			[]Data{testData(func(d *Data) { d.ID = 1 }), testData(func(d *Data) { d.ID = 2 })}, ""},
+		{"sniffed delimiter", strings.ReplaceAll(testCSV(testRow(nil)), ",", ";"), inputOptions{sniffDelimiter: true}, []Data{testData(nil)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warns := newWarnings(false)
+			got, err := extractData(context.Background(), strings.NewReader(tt.in), tt.opts, warns, &rowErrors{warns: warns})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("extractData returned %v, want an error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractData returned\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformRow(t *testing.T) {
+	warns := newWarnings(false)
+	got := transformRow(7, testData(nil), warns)
+	want := Kickstart{
+		Product:        Product{ID: 7, KickstarterID: 1000003930, Name: "Greeting From Earth: ZGAC Arts Capsule For ET"},
+		MainCategory:   MainCategory{ID: 7, Name: "Film & Video"},
+		Category:       Category{ID: 7, Name: "Narrative Film"},
+		Currency:       Currency{ID: 7, Type: "USD"},
+		Date:           Date{ID: 7, Launched: time.Date(2017, 9, 2, 4, 43, 57, 0, time.UTC), Deadline: time.Date(2017, 11, 1, 0, 0, 0, 0, time.UTC)},
+		State:          State{ID: 7, State: "failed"},
+		Area:           Area{ID: 7, Country: "US"},
+		ProductID:      7,
+		MainCategoryID: 7,
+		CategoryID:     7,
+		CurrencyID:     7,
+		DateID:         7,
+		StateID:        7,
+		AreaID:         7,
+		Backers:        15,
+		Goal:           30000,
+		GoalUSDReal:    sql.NullFloat64{Float64: 30000, Valid: true},
+		Pledged:        2421,
+		PledgedUSD:     sql.NullFloat64{Float64: 100, Valid: true},
+		PledgedUSDReal: sql.NullFloat64{Float64: 2421, Valid: true},
+		DurationDays:   60,
+		FundingPct:     sql.NullFloat64{Float64: 2421.0 / 30000 * 100, Valid: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("transformRow returned\n%+v\nwant\n%+v", got, want)
+	}
+	if n := warns.total(); n != 0 {
+		t.Errorf("transforming a clean row warned %d times", n)
+	}
+}
+
+func TestTransformData(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  Data
+		check func(k Kickstart) bool
+		warn  string
+	}{
+		{"unknown country", testData(func(d *Data) { d.Country = undefinedCountry }),
+			func(k Kickstart) bool { return k.Area.Country == unknownCountry }, ""},
+		{"empty usd pledged", testData(func(d *Data) { d.PledgedUSD = sql.NullFloat64{} }),
+			func(k Kickstart) bool { return !k.PledgedUSD.Valid }, "no usd pledged"},
+		{"zero goal", testData(func(d *Data) { d.GoalUSDReal = sql.NullFloat64{Valid: true} }),
+			func(k Kickstart) bool { return !k.FundingPct.Valid }, ""},
+		{"deadline before launch", testData(func(d *Data) { d.Deadline = d.Launched.AddDate(0, 0, -1) }),
+			func(k Kickstart) bool { return k.DurationDays == -1 }, "non-positive duration"},
+		{"padded names", testData(func(d *Data) { d.Name, d.Category, d.Country = " A \t B ", " Music ", " US" }),
+			func(k Kickstart) bool {
+				return k.Product.Name == "A B" && k.Category.Name == "Music" && k.Area.Country == "US"
+			}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warns := newWarnings(false)
+			kk, err := transformData(context.Background(), []Data{tt.data}, rowFilter{}, warns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(kk) != 1 {
+				t.Fatalf("transformData returned %d kickstarts, want 1", len(kk))
+			}
+			if !tt.check(kk[0]) {
+				t.Errorf("transformData returned %+v", kk[0])
+			}
+			if tt.warn != "" && warns.counts[tt.warn] != 1 {
+				t.Errorf("transformData warned %v, want a %s warning", warns.counts, tt.warn)
+			}
+			if tt.warn == "" && warns.total() != 0 {
+				t.Errorf("transformData warned %v, want no warning", warns.counts)
+			}
+		})
+	}
+}
+
+func TestTransformDataIDs(t *testing.T) {
+	dd := []Data{
+		testData(func(d *Data) { d.ID = 1 }),
+		testData(func(d *Data) { d.ID = 2 }),
+		testData(func(d *Data) { d.ID = 1 }),
+	}
+	kk, err := transformData(context.Background(), dd, rowFilter{seen: make(map[int64]bool)}, newWarnings(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kk) != 2 {
+		t.Fatalf("transformData returned %d kickstarts, want the repeated kickstarter_id dropped", len(kk))
+	}
+	// The ids are those of the rows of the input, which the loader replaces
+	// with the ids of the rows of the database.
+	for i, k := range kk {
+		if want := int64(i + 1); k.ProductID != want || k.Category.ID != want || k.AreaID != want {
+			t.Errorf("kickstart %d has the ids %d, %d and %d, want %d", i, k.ProductID, k.Category.ID, k.AreaID, want)
+		}
+	}
+}
+
+func TestExtractDates(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   map[string]string
+		deadline time.Time
+		wantErr  string
+	}{
+		{"deadline", nil, time.Date(2017, 11, 1, 0, 0, 0, 0, time.UTC), ""},
+		{"deadline with a time", map[string]string{"deadline": "2016-11-01 12:00:00"}, time.Date(2016, 11, 1, 12, 0, 0, 0, time.UTC), ""},
+		{"garbage deadline", map[string]string{"deadline": "2017-13-01"}, time.Time{}, `line 3: parsing deadline "2017-13-01"`},
+		{"garbage launched", map[string]string{"launched": "yesterday"}, time.Time{}, `line 3: parsing launched "yesterday"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := map[string]string{"ID": "2"}
+			for k, v := range tt.change {
+				change[k] = v
+			}
+			warns := newWarnings(false)
+			in := testCSV(testRow(map[string]string{"ID": "1"}), testRow(change))
+			dd, err := extractData(context.Background(), strings.NewReader(in), inputOptions{}, warns, &rowErrors{warns: warns})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("extractData returned %v, want an error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !dd[1].Deadline.Equal(tt.deadline) {
+				t.Errorf("deadline is %v, want %v", dd[1].Deadline, tt.deadline)
+			}
+		})
+	}
+}
+
+func TestNormalizeSpace(t *testing.T) {
+	tests := map[string]string{
+		"Music":            "Music",
+		" Music ":          "Music",
+		"Film \t&\n Video": "Film & Video",
+		"   ":              "",
+	}
+	for in, want := range tests {
+		if got := normalizeSpace(in); got != want {
+			t.Errorf("normalizeSpace(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFundingPct(t *testing.T) {
+	valid := func(f float64) sql.NullFloat64 { return sql.NullFloat64{Float64: f, Valid: true} }
+	tests := []struct {
+		pledged, goal, want sql.NullFloat64
+	}{
+		{valid(50), valid(200), valid(25)},
+		{valid(300), valid(100), valid(300)},
+		{valid(50), valid(0), sql.NullFloat64{}},
+		{sql.NullFloat64{}, valid(100), sql.NullFloat64{}},
+		{valid(50), sql.NullFloat64{}, sql.NullFloat64{}},
+	}
+	for _, tt := range tests {
+		if got := fundingPct(tt.pledged, tt.goal); got != tt.want {
+			t.Errorf("fundingPct(%v, %v) = %v, want %v", tt.pledged, tt.goal, got, tt.want)
+		}
+	}
+}
+
+func TestDurationDays(t *testing.T) {
+	launched := time.Date(2017, 9, 2, 4, 43, 57, 0, time.UTC)
+	tests := []struct {
+		deadline time.Time
+		want     int
+	}{
+		{time.Date(2017, 11, 1, 0, 0, 0, 0, time.UTC), 60},
+		{launched.Add(36 * time.Hour), 2},
+		{launched.Add(11 * time.Hour), 0},
+		{launched, 0},
+		{launched.AddDate(0, 0, -3), -3},
+	}
+	for _, tt := range tests {
+		if got := durationDays(launched, tt.deadline); got != tt.want {
+			t.Errorf("durationDays(%v, %v) = %d, want %d", launched, tt.deadline, got, tt.want)
+		}
+	}
+}
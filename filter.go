@@ -0,0 +1,147 @@
+package etl
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// rowFilter selects the kickstarts that are loaded. A kickstart is kept only
+// if it passes every condition. The zero value keeps every kickstart.
+type rowFilter struct {
+	// states are the states that are kept. A nil map keeps every state.
+	states map[string]bool
+	// countries are the countries that are kept, in upper case. A nil map
+	// keeps every country.
+	countries map[string]bool
+	// from and to bound the launched date of the kickstarts that are kept.
+	// from is inclusive and to is exclusive. A zero time leaves its side of
+	// the range open.
+	from, to time.Time
+	// loaded are the kickstarter_ids that an earlier load already loaded.
+	// They are skipped when a load is resumed.
+	loaded map[int64]bool
+	// seen are the kickstarter_ids of the kickstarts kept so far. Later
+	// occurrences of them are dropped so that only the first one is loaded.
+	// A nil map keeps every occurrence.
+	seen map[int64]bool
+	// skipped counts the kickstarts that were dropped. A nil pointer counts
+	// nothing.
+	skipped *skipCounts
+}
+
+// skipCounts counts the kickstarts a rowFilter dropped by why they were
+// dropped.
+type skipCounts struct {
+	// Filtered did not pass --states, --countries, --from or --to.
+	Filtered int
+	// AlreadyLoaded were loaded by the earlier load that is resumed.
+	AlreadyLoaded int
+	// Duplicates repeat the kickstarter_id of a kickstart that is kept.
+	Duplicates int
+}
+
+// total returns the number of kickstarts that were dropped.
+func (c *skipCounts) total() int {
+	return c.Filtered + c.AlreadyLoaded + c.Duplicates
+}
+
+// log logs the counts if any kickstart was dropped, with the number of those
+// that remain.
+func (c *skipCounts) log(remaining int) {
+	if c.total() == 0 {
+		return
+	}
+	slog.Info("Skipped rows", "filtered", c.Filtered, "already_loaded", c.AlreadyLoaded, "duplicates", c.Duplicates, "remaining", remaining)
+}
+
+// parseRangeDate parses the bound of a launched date range given as an RFC
+// 3339 date, e.g. 2016-12-31, or date and time. A date given as the end of
+// the range includes the whole day.
+func parseRangeDate(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		if end {
+			// The end of the range is exclusive.
+			t = t.Add(time.Nanosecond)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 date like 2016-12-31", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parseCountrySet parses a comma separated list of countries as they appear
+// in the dataset, e.g. US,GB.
+func parseCountrySet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, country := range strings.Split(s, ",") {
+		if country = strings.ToUpper(strings.TrimSpace(country)); country != "" {
+			set[country] = true
+		}
+	}
+	return set
+}
+
+// keep reports whether k passes every condition of f. When a date range is
+// given, a kickstart without a launched date is dropped with a warning, as is
+// a repeated kickstarter_id if f keeps only the first occurrence.
+func (f rowFilter) keep(k Kickstart, warns *warnings) bool {
+	id := k.Product.KickstarterID
+	if f.loaded[id] {
+		if f.skipped != nil {
+			f.skipped.AlreadyLoaded++
+		}
+		return false
+	}
+	if !f.match(k, warns) {
+		if f.skipped != nil {
+			f.skipped.Filtered++
+		}
+		return false
+	}
+	if f.seen == nil {
+		return true
+	}
+	if f.seen[id] {
+		warns.warn("duplicate kickstarter_id", "kickstarter_id %d appears more than once, keeping the first occurrence", id)
+		if f.skipped != nil {
+			f.skipped.Duplicates++
+		}
+		return false
+	}
+	f.seen[id] = true
+	return true
+}
+
+// match reports whether k passes the conditions of f other than loaded and
+// seen.
+func (f rowFilter) match(k Kickstart, warns *warnings) bool {
+	if f.states != nil && !f.states[normalizeState(k.State.State)] {
+		return false
+	}
+	if f.countries != nil && !f.countries[strings.ToUpper(k.Area.Country)] {
+		return false
+	}
+	if f.from.IsZero() && f.to.IsZero() {
+		return true
+	}
+	launched := k.Date.Launched
+	if launched.IsZero() {
+		warns.warn("no launched date", "kickstarter_id %d has no launched date to compare with the date range", k.Product.KickstarterID)
+		return false
+	}
+	if !f.from.IsZero() && launched.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && !launched.Before(f.to) {
+		return false
+	}
+	return true
+}
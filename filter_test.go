@@ -0,0 +1,33 @@
+package etl
+
+import "testing"
+
+func TestRowFilterSkipCounts(t *testing.T) {
+	kk := testKickstarts(t,
+		testRow(nil),
+		testRow(map[string]string{"ID": "2"}),
+		testRow(map[string]string{"ID": "3", "state": "successful"}),
+		testRow(map[string]string{"ID": "4"}),
+		testRow(map[string]string{"ID": "4"}),
+		testRow(map[string]string{"ID": "5"}),
+	)
+	f := rowFilter{
+		states:  parseStateSet("failed"),
+		loaded:  map[int64]bool{2: true},
+		seen:    make(map[int64]bool),
+		skipped: &skipCounts{},
+	}
+	kept := 0
+	for _, k := range kk {
+		if f.keep(k, newWarnings(false)) {
+			kept++
+		}
+	}
+	if kept != 3 {
+		t.Errorf("kept %d kickstarts, want 3", kept)
+	}
+	want := skipCounts{Filtered: 1, AlreadyLoaded: 1, Duplicates: 1}
+	if *f.skipped != want {
+		t.Errorf("skipped = %+v, want %+v", *f.skipped, want)
+	}
+}
@@ -0,0 +1,127 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// testHeader is the header of the kickstarter CSV.
+const testHeader = "ID,name,category,main_category,currency,deadline,goal,launched,pledged,state,backers,country,usd pledged,usd_pledged_real,usd_goal_real"
+
+// testFields are the fields of a valid row of the kickstarter CSV by column.
+var testFields = map[string]string{
+	"ID":               "1000003930",
+	"name":             "Greeting From Earth: ZGAC Arts Capsule For ET",
+	"category":         "Narrative Film",
+	"main_category":    "Film & Video",
+	"currency":         "USD",
+	"deadline":         "2017-11-01",
+	"goal":             "30000.00",
+	"launched":         "2017-09-02 04:43:57",
+	"pledged":          "2421.00",
+	"state":            "failed",
+	"backers":          "15",
+	"country":          "US",
+	"usd pledged":      "100.00",
+	"usd_pledged_real": "2421.00",
+	"usd_goal_real":    "30000.00",
+}
+
+// testRow returns a line of the kickstarter CSV with the fields of testFields
+// except for those given in change. A change of a field that holds a comma or
+// a quote must quote it.
+func testRow(change map[string]string) string {
+	var fields []string
+	for _, c := range strings.Split(testHeader, ",") {
+		v, ok := change[c]
+		if !ok {
+			v = testFields[c]
+		}
+		fields = append(fields, v)
+	}
+	return strings.Join(fields, ",")
+}
+
+// testCSV returns the kickstarter CSV of the given rows.
+func testCSV(rows ...string) string {
+	return testHeader + "\n" + strings.Join(rows, "\n") + "\n"
+}
+
+// writeTestFile writes content to a file in a temporary directory of the test
+// and returns its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testConfig returns the configuration of the etl command with the defaults of
+// its flags that reads the input at path and writes it as csv to out.
+func testConfig(input, out string) Config {
+	return Config{
+		Driver:         "sqlite",
+		Input:          input,
+		Output:         "csv",
+		Out:            out,
+		ValidateStates: true,
+		AllowedStates:  KnownStates,
+		BatchSize:      1000,
+		MaxRetries:     3,
+		Workers:        1,
+		InputFormat:    csvInput,
+		Delimiter:      ",",
+		Report:         "text",
+		Encoding:       "utf-8",
+		DedupKeep:      dedupFirst,
+	}
+}
+
+// testDBConfig returns testConfig changed to load the input at path into a
+// new SQLite database, and that database.
+func testDBConfig(t *testing.T, input string) (Config, *sql.DB) {
+	t.Helper()
+	c := testConfig(input, "")
+	c.Output = "db"
+	c.DataSource = filepath.Join(t.TempDir(), "kickstarter.db")
+	db, err := sql.Open("sqlite", c.DataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return c, db
+}
+
+// queryInt returns the integer that query selects from db.
+func queryInt(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+// testKickstarts extracts and transforms the given lines of the kickstarter
+// CSV, which come after its header.
+func testKickstarts(t *testing.T, rows ...string) []Kickstart {
+	t.Helper()
+	warns := newWarnings(false)
+	dd, err := extractData(context.Background(), strings.NewReader(testCSV(rows...)), inputOptions{}, warns, &rowErrors{warns: warns})
+	if err != nil {
+		t.Fatalf("extracting data: %v", err)
+	}
+	kk, err := transformData(context.Background(), dd, rowFilter{}, warns)
+	if err != nil {
+		t.Fatalf("transforming data: %v", err)
+	}
+	return kk
+}
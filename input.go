@@ -0,0 +1,334 @@
+package etl
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/transform"
+)
+
+// Formats of the input.
+const (
+	csvInput       = "csv"
+	jsonLinesInput = "jsonl"
+	xlsxInput      = "xlsx"
+)
+
+// inputOptions configure how the input is read.
+type inputOptions struct {
+	// format is csvInput, jsonLinesInput or xlsxInput. Empty means csvInput.
+	format string
+	// maxRows is the most data rows that are read. Zero means all of them.
+	maxRows int
+	// delimiter separates the fields of the CSV input. Zero means a comma.
+	delimiter rune
+	// sniffDelimiter makes the delimiter be detected from the CSV header.
+	sniffDelimiter bool
+	// encoding is the character encoding of the input. Nil means UTF-8.
+	encoding encoding.Encoding
+	// lazyQuotes accepts bare quotes in the fields of the CSV input, such as
+	// in The 6" Figure, instead of failing on them.
+	lazyQuotes bool
+}
+
+// parseEncoding returns the character encoding named s: utf-8, latin1 or
+// windows-1252. It is nil for UTF-8 which needs no decoding.
+func parseEncoding(s string) (encoding.Encoding, error) {
+	switch strings.ToLower(s) {
+	case "utf-8", "utf8":
+		return nil, nil
+	case "latin1", "latin-1", "iso-8859-1":
+		return charmap.ISO8859_1, nil
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %q (must be utf-8, latin1 or windows-1252)", s)
+	}
+}
+
+// decode returns a reader of r converted from enc to UTF-8. A nil enc leaves r
+// as it is; see validUTF8 for what happens to invalid UTF-8 then.
+func decode(r io.Reader, enc encoding.Encoding) io.Reader {
+	if enc == nil {
+		return r
+	}
+	return transform.NewReader(r, enc.NewDecoder())
+}
+
+// validUTF8 replaces every invalid UTF-8 sequence in the fields of row with
+// U+FFFD instead of failing the load, so that the database never receives
+// invalid UTF-8. Fields are only copied if they are invalid, which is rare
+// enough that this is much cheaper than decoding the whole input.
+func validUTF8(row []string) {
+	for i, f := range row {
+		if !utf8.ValidString(f) {
+			row[i] = strings.ToValidUTF8(f, "\uFFFD")
+		}
+	}
+}
+
+// utf8BOM is the byte order mark that Excel and other tools write at the start
+// of UTF-8 files.
+const utf8BOM = "\xef\xbb\xbf"
+
+// skipBOM returns a reader of r that skips its leading UTF-8 byte order mark,
+// if it has one. Otherwise the mark would stick to the first column name.
+func skipBOM(r io.Reader) *bufio.Reader {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(len(utf8BOM)); err == nil && string(b) == utf8BOM {
+		br.Discard(len(utf8BOM))
+	}
+	return br
+}
+
+// stdinInput is the input path that reads from stdin.
+const stdinInput = "-"
+
+// isFileInput reports whether the input path names a local file, as opposed
+// to stdin or a URL.
+func isFileInput(path string) bool {
+	return path != stdinInput && !isURLInput(path)
+}
+
+// inputFiles returns the paths of the files in the directory at path, such as
+// a directory of monthly files, in the order of their names. Subdirectories and
+// hidden files are skipped. It returns nil if path is not a directory.
+func inputFiles(path string) ([]string, error) {
+	if !isFileInput(path) {
+		return nil, nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil || !fi.IsDir() {
+		// A missing file is reported when it is opened.
+		return nil, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading input directory: %v", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		files = append(files, filepath.Join(path, e.Name()))
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("input directory %s has no files", path)
+	}
+	return files, nil
+}
+
+// openInputs is openInput for the input at path, unless files are the files of
+// the directory at path which the extractor of inputExtractor opens itself.
+func openInputs(ctx context.Context, path string, files []string, timeout time.Duration) (io.ReadCloser, string, error) {
+	if files != nil {
+		return io.NopCloser(strings.NewReader("")), path, nil
+	}
+	return openInput(ctx, path, timeout)
+}
+
+// openInput opens the kickstarter CSV data at path. A .csv or .xlsx file is
+// read directly, a .gz file is decompressed and the first .csv file in a .zip
+// file is read. Files with any other extension are detected by their first
+// bytes. A zip archive with the parts of an Excel workbook is read as is, but
+// openInput does not pick the input format: it is only extracted as a
+// workbook if the user passes --input-format xlsx, and otherwise fails with an
+// error that suggests it, see eachRow. A path of - reads the data from stdin as
+// is and an http, https or s3 URL is fetched, see openURL and openS3. It also
+// returns the name of the CSV that is read.
+func openInput(ctx context.Context, path string, timeout time.Duration) (io.ReadCloser, string, error) {
+	if path == stdinInput {
+		return io.NopCloser(os.Stdin), "stdin", nil
+	}
+	if isS3Input(path) {
+		return openS3(ctx, path)
+	}
+	if isURLInput(path) {
+		return openURL(ctx, path, timeout)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".xlsx":
+		return openCSV(path)
+	case ".gz":
+		return openGzip(path)
+	case ".zip":
+		return openZipEntry(path)
+	}
+	format, err := sniffFormat(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening input %s: %v", path, err)
+	}
+	switch format {
+	case "zip":
+		return openZipEntry(path)
+	case "gzip":
+		return openGzip(path)
+	default:
+		return openCSV(path)
+	}
+}
+
+// workbookPart is the zip entry that every Excel workbook has.
+const workbookPart = "xl/workbook.xml"
+
+var (
+	// zipMagic are the first bytes of a zip archive.
+	zipMagic = []byte("PK\x03\x04")
+	// gzipMagic are the first bytes of a gzip stream.
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// sniffFormat returns "zip" or "gzip" if the file at path starts like a zip
+// archive or gzip stream and "csv" otherwise. A zip archive is "xlsx" instead
+// if it holds an Excel workbook.
+func sniffFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(zipMagic))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	magic = magic[:n]
+	switch {
+	case bytes.HasPrefix(magic, zipMagic):
+		zipr, err := zip.OpenReader(path)
+		if err != nil {
+			return "", err
+		}
+		defer zipr.Close()
+		for _, zf := range zipr.File {
+			if zf.Name == workbookPart {
+				return xlsxInput, nil
+			}
+		}
+		return "zip", nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		return "gzip", nil
+	default:
+		return "csv", nil
+	}
+}
+
+func openCSV(path string) (io.ReadCloser, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening input %s: %v", path, err)
+	}
+	return f, filepath.Base(path), nil
+}
+
+// gzipFile decompresses a gzip file and adds the path of the file to any
+// error so that a corrupt stream can be traced back to its file.
+type gzipFile struct {
+	path string
+	f    io.ReadCloser
+	gzr  *gzip.Reader
+}
+
+// newGzipFile returns a reader of the decompressed data of f, the gzip file at
+// path. It closes f if the data is not gzip.
+func newGzipFile(path string, f io.ReadCloser) (*gzipFile, error) {
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading gzip file %s: %v", path, err)
+	}
+	return &gzipFile{path: path, f: f, gzr: gzr}, nil
+}
+
+func openGzip(path string) (io.ReadCloser, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening input %s: %v", path, err)
+	}
+	g, err := newGzipFile(path, f)
+	if err != nil {
+		return nil, "", err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return g, name, nil
+}
+
+func (g *gzipFile) Read(p []byte) (int, error) {
+	n, err := g.gzr.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("reading gzip file %s: %v", g.path, err)
+	}
+	return n, err
+}
+
+func (g *gzipFile) Close() error {
+	err := g.gzr.Close()
+	if ferr := g.f.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// zipEntry closes both an entry of a zip archive and the archive itself.
+type zipEntry struct {
+	io.ReadCloser
+	zipr *zip.ReadCloser
+}
+
+func (z zipEntry) Close() error {
+	err := z.ReadCloser.Close()
+	if zerr := z.zipr.Close(); err == nil {
+		err = zerr
+	}
+	return err
+}
+
+func openZipEntry(path string) (io.ReadCloser, string, error) {
+	zipr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading zip file %s: %v", path, err)
+	}
+	for _, zf := range zipr.File {
+		if !isDatasetEntry(zf.Name) {
+			continue
+		}
+		f, err := zf.Open()
+		if err != nil {
+			zipr.Close()
+			return nil, "", fmt.Errorf("reading data from %s: %v", path, err)
+		}
+		return zipEntry{ReadCloser: f, zipr: zipr}, zf.Name, nil
+	}
+	zipr.Close()
+	for _, zf := range zipr.File {
+		if zf.Name == workbookPart {
+			return nil, "", fmt.Errorf("zip file %s is an Excel workbook, not an archive of a .csv file, rename it to .xlsx", path)
+		}
+	}
+	return nil, "", fmt.Errorf("zip file %s does not contain a .csv file", path)
+}
+
+// isDatasetEntry reports whether the zip entry name is a CSV file. Entries in
+// __MACOSX and the ._ files of macOS hold metadata of other entries and are not
+// CSV files even if their name ends in .csv.
+func isDatasetEntry(name string) bool {
+	if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._") {
+		return false
+	}
+	return strings.EqualFold(path.Ext(name), ".csv")
+}
@@ -0,0 +1,221 @@
+package etl
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testZip returns a zip archive that holds an empty entry of each name.
+func testZip(t *testing.T, names ...string) string {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range names {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestSniffFormat(t *testing.T) {
+	var gz bytes.Buffer
+	gzw := gzip.NewWriter(&gz)
+	gzw.Write([]byte(testHeader))
+	gzw.Close()
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"csv", testCSV(testRow(nil)), "csv"},
+		{"empty", "", "csv"},
+		{"gzip", gz.String(), "gzip"},
+		{"zip", testZip(t, "ks-projects-201801.csv"), "zip"},
+		{"workbook", testZip(t, "[Content_Types].xml", "xl/workbook.xml", "xl/worksheets/sheet1.xml"), xlsxInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sniffFormat(writeTestFile(t, "input", tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("sniffFormat = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenZipEntryWorkbook(t *testing.T) {
+	path := writeTestFile(t, "book.zip", testZip(t, "xl/workbook.xml"))
+	_, _, err := openZipEntry(path)
+	if err == nil || !strings.Contains(err.Error(), "Excel workbook") {
+		t.Fatalf("openZipEntry returned %v, want an error about an Excel workbook", err)
+	}
+}
+
+func TestEachRowZipAsCSV(t *testing.T) {
+	r := strings.NewReader(testZip(t, "xl/workbook.xml"))
+	err := eachRow(context.Background(), r, inputOptions{}, newWarnings(false), &rowErrors{}, func(Data) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "--input-format xlsx") {
+		t.Fatalf("eachRow returned %v, want an error that suggests --input-format xlsx", err)
+	}
+}
+
+func TestExtractBOM(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		rows    int
+	}{
+		{"rows", utf8BOM + testCSV(testRow(nil)), 1},
+		{"header only", utf8BOM + testHeader, 0},
+	}
+	for _, tt := range tests {
+		warns := newWarnings(false)
+		dd, err := extractData(context.Background(), strings.NewReader(tt.content), inputOptions{}, warns, &rowErrors{warns: warns})
+		if err != nil {
+			t.Fatalf("%s: extracting a CSV that starts with a BOM: %v", tt.name, err)
+		}
+		if len(dd) != tt.rows {
+			t.Errorf("%s: extracted %d rows, want %d", tt.name, len(dd), tt.rows)
+		}
+	}
+}
+
+// testInputDir writes a directory of monthly files of the kickstarter CSV and
+// returns its path. A kickstarter_id is repeated across files and a category
+// is in all of them.
+func testInputDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string][]string{
+		"2018-01.csv": {
+			testRow(map[string]string{"ID": "1", "name": "January", "country": "US"}),
+			testRow(map[string]string{"ID": "2", "name": "Repeated first", "country": "GB"}),
+		},
+		"2018-02.csv": {
+			testRow(map[string]string{"ID": "3", "name": "February", "country": "DE"}),
+		},
+		"2018-03.csv": {
+			testRow(map[string]string{"ID": "2", "name": "Repeated last", "country": "FR"}),
+			testRow(map[string]string{"ID": "4", "name": "March", "country": "IT"}),
+		},
+	}
+	for name, rows := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(testCSV(rows...)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".hidden.csv"), []byte("not a csv"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// dumpKickstarts returns the kickstarts loaded into db with the ids of their
+// rows, one line each.
+func dumpKickstarts(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	rows, err := db.Query(`SELECT k.id, p.id, p.kickstarter_id, p.name, a.id, a.country, c.id
+		FROM kickstarts k JOIN products p ON p.id = k.product_id JOIN areas a ON a.id = k.area_id JOIN categories c ON c.id = k.category_id
+		ORDER BY k.id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var b strings.Builder
+	for rows.Next() {
+		var (
+			factID, productID, kickstarterID, areaID, categoryID int64
+			name, country                                        string
+		)
+		if err := rows.Scan(&factID, &productID, &kickstarterID, &name, &areaID, &country, &categoryID); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(&b, factID, productID, kickstarterID, name, areaID, country, categoryID)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestInputDirectory(t *testing.T) {
+	dir := testInputDir(t)
+	var dumps []string
+	for _, workers := range []int{1, 3} {
+		c, db := testDBConfig(t, dir)
+		c.ExtractWorkers = workers
+		if err := Run(context.Background(), c); err != nil {
+			t.Fatalf("extract workers %d: %v", workers, err)
+		}
+		dumps = append(dumps, dumpKickstarts(t, db))
+	}
+	want := `1 1 1 January 1 US 1
+2 2 2 Repeated first 2 GB 1
+3 3 3 February 3 DE 1
+4 4 4 March 4 IT 1
+`
+	for i, dump := range dumps {
+		if dump != want {
+			t.Errorf("load %d is\n%s\nwant\n%s", i+1, dump, want)
+		}
+	}
+}
+
+func TestInputDirectoryError(t *testing.T) {
+	dir := testInputDir(t)
+	if err := os.WriteFile(filepath.Join(dir, "2018-02.csv"), []byte("no,header\n1,2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := testDBConfig(t, dir)
+	c.ExtractWorkers = 2
+	err := Run(context.Background(), c)
+	if err == nil || !strings.Contains(err.Error(), "2018-02.csv") {
+		t.Fatalf("Run returned %v, want an error about 2018-02.csv", err)
+	}
+}
+
+func TestInputDirectorySkippedRows(t *testing.T) {
+	dir := testInputDir(t)
+	bad := testCSV(testRow(map[string]string{"ID": "5"}), testRow(map[string]string{"ID": "6", "backers": "many"}))
+	if err := os.WriteFile(filepath.Join(dir, "2018-04.csv"), []byte(bad), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	warns := newWarnings(false)
+	errs := &rowErrors{skip: true, warns: warns}
+	files, err := inputFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := inputExtractor(inputOptions{format: csvInput}, files, 4, 0, warns, errs)
+	dd, err := e.Extract(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dd) != 6 {
+		t.Errorf("extracted %d rows, want 6", len(dd))
+	}
+	if errs.rows != 7 || len(errs.errs) != 1 {
+		t.Fatalf("counted %d rows and %d errors, want 7 and 1", errs.rows, len(errs.errs))
+	}
+	if got := errs.errs[0].Error(); !strings.HasPrefix(got, "2018-04.csv: line 3: ") {
+		t.Errorf("row error is %q, want it to name 2018-04.csv line 3", got)
+	}
+	if n := warns.counts["skipped row"]; n != 1 {
+		t.Errorf("counted %d skipped row warnings, want 1", n)
+	}
+}
@@ -0,0 +1,44 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// countOrphans returns the number of rows of t whose foreign key fk does not
+// match any row of the referenced table.
+func countOrphans(ctx context.Context, db *sql.DB, d dialect, t table, fk foreignKey) (int, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*)
+		FROM %[1]s c
+		LEFT JOIN %[3]s p ON c.%[2]s = p.%[4]s
+		WHERE c.%[2]s IS NOT NULL AND p.%[4]s IS NULL`, d.table(t.Name), fk.Column, d.table(fk.RefTable), fk.RefColumn)
+	var count int
+	if err := db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// validateForeignKeys checks every foreign key of tables for orphaned rows and
+// prints the count per relationship. It returns an error if any orphans were
+// found. This re-verifies integrity after a load that bypassed the foreign key
+// constraints.
+func validateForeignKeys(ctx context.Context, db *sql.DB, d dialect, tables []table) error {
+	total := 0
+	for _, t := range tables {
+		for _, fk := range t.ForeignKeys {
+			n, err := countOrphans(ctx, db, d, t, fk)
+			if err != nil {
+				return fmt.Errorf("checking %s.%s: %v", t.Name, fk.Column, err)
+			}
+			fmt.Printf("%s.%s -> %s.%s: %d orphans\n", d.table(t.Name), fk.Column, d.table(fk.RefTable), fk.RefColumn, n)
+			total += n
+		}
+	}
+	if total != 0 {
+		return fmt.Errorf("found %d rows with dangling foreign keys", total)
+	}
+	return nil
+}
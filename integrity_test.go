@@ -0,0 +1,45 @@
+package etl
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateForeignKeys(t *testing.T) {
+	ctx := context.Background()
+	d := dialect{kind: sqliteDialect}
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(
+		testRow(map[string]string{"ID": "1"}),
+		testRow(map[string]string{"ID": "2", "country": "GB"}),
+	)))
+	if err := Run(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateForeignKeys(ctx, db, d, schemaTables); err != nil {
+		t.Fatalf("validating a clean load: %v", err)
+	}
+
+	// SQLite does not enforce the foreign keys unless it is asked to, so
+	// the fact row can be orphaned.
+	if _, err := db.Exec("UPDATE kickstarts SET area_id = 9999 WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	err := validateForeignKeys(ctx, db, d, schemaTables)
+	if err == nil || !strings.Contains(err.Error(), "found 1 rows with dangling foreign keys") {
+		t.Fatalf("validating an orphaned kickstart returned %v, want 1 dangling row", err)
+	}
+	fk := foreignKey{Column: "area_id", RefTable: "areas", RefColumn: "id"}
+	for _, table := range schemaTables {
+		if table.Name != "kickstarts" {
+			continue
+		}
+		n, err := countOrphans(ctx, db, d, table, fk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("kickstarts.area_id has %d orphans, want 1", n)
+		}
+	}
+}
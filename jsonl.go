@@ -0,0 +1,149 @@
+package etl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// JSONLinesExtractor extracts rows from newline-delimited JSON, one object
+// per line with the fields of Data.
+type JSONLinesExtractor struct {
+	// maxRows is the most data rows that are read. Zero means all of them.
+	maxRows int
+	errs    *rowErrors
+}
+
+func (e *JSONLinesExtractor) Extract(ctx context.Context, r io.Reader) ([]Data, error) {
+	errs := e.errs
+	if errs == nil {
+		errs = &rowErrors{}
+	}
+	return extractData(ctx, r, inputOptions{format: jsonLinesInput, maxRows: e.maxRows}, newWarnings(false), errs)
+}
+
+// jsonLine is a row of the JSON-lines input. The dates use the layouts of the
+// kickstarter CSV.
+//
+// Lines are not decoded straight into Data because encoding/json cannot fill
+// its fields: it only decodes a time.Time from RFC 3339, not from the layouts
+// of the CSV, and sql.NullFloat64 has no JSON decoding, so a null or missing
+// measure would fail the line. Decoding the dates as strings also lets
+// parseJSONLine report a bad date as a rowError of its column, like the CSV.
+type jsonLine struct {
+	ID             int64    `json:"id"`
+	Name           string   `json:"name"`
+	Category       string   `json:"category"`
+	MainCategory   string   `json:"main_category"`
+	Currency       string   `json:"currency"`
+	Deadline       string   `json:"deadline"`
+	Launched       string   `json:"launched"`
+	State          string   `json:"state"`
+	Country        string   `json:"country"`
+	Backers        int      `json:"backers"`
+	Pledged        float64  `json:"pledged"`
+	PledgedUSD     *float64 `json:"pledged_usd"`
+	PledgedUSDReal *float64 `json:"pledged_usd_real"`
+	Goal           float64  `json:"goal"`
+	GoalUSDReal    *float64 `json:"goal_usd_real"`
+}
+
+// maxJSONLine is the longest line of the JSON-lines input.
+const maxJSONLine = 1 << 20
+
+// eachJSONLine is eachRow for newline-delimited JSON. Blank lines are
+// ignored.
+func eachJSONLine(ctx context.Context, r io.Reader, maxRows int, errs *rowErrors, fn func(d Data) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxJSONLine)
+	for line, n := 0, 0; maxRows <= 0 || n < maxRows; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !sc.Scan() {
+			return sc.Err()
+		}
+		line++
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		n++
+		errs.rows++
+		d, err := parseJSONLine(b)
+		if rerr, ok := err.(*rowError); ok {
+			rerr.Line = line
+		}
+		if err != nil {
+			if err := errs.add(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseJSONLine parses a line of the JSON-lines input. Its errors are
+// *rowError without a line.
+func parseJSONLine(b []byte) (Data, error) {
+	var l jsonLine
+	if err := json.Unmarshal(b, &l); err != nil {
+		return Data{}, &rowError{Column: "json", Value: truncate(string(b), 40), Err: err}
+	}
+	d := Data{
+		ID:           l.ID,
+		Name:         l.Name,
+		Category:     l.Category,
+		MainCategory: l.MainCategory,
+		Currency:     l.Currency,
+		State:        l.State,
+		Country:      l.Country,
+		Backers:      l.Backers,
+		Pledged:      l.Pledged,
+		Goal:         l.Goal,
+	}
+	d.PledgedUSD = ptrNullFloat(l.PledgedUSD)
+	d.PledgedUSDReal = ptrNullFloat(l.PledgedUSDReal)
+	d.GoalUSDReal = ptrNullFloat(l.GoalUSDReal)
+	deadline, err := time.Parse(deadlineLayout, l.Deadline)
+	if err != nil {
+		return Data{}, &rowError{Column: "deadline", Value: l.Deadline, Err: err}
+	}
+	d.Deadline = deadline
+	launched, err := time.Parse(launchedLayout, l.Launched)
+	if err != nil {
+		return Data{}, &rowError{Column: "launched", Value: l.Launched, Err: err}
+	}
+	d.Launched = launched
+	return d, nil
+}
+
+// ptrNullFloat returns the value of f or NULL if f is nil.
+func ptrNullFloat(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
+
+// truncate shortens s to at most n bytes followed by an ellipsis. It cuts at
+// the start of a rune so that a multibyte character is not split into invalid
+// UTF-8.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,86 @@
+package etl
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"io"
+)
+
+// jsonRecord is a kickstart as written by jsonSink. Its fields are named after
+// the columns of the cleaned CSV. The amounts in the reporting currency are
+// null without --rates.
+type jsonRecord struct {
+	KickstarterID    int64    `json:"kickstarter_id"`
+	Name             string   `json:"name"`
+	Category         string   `json:"category"`
+	MainCategory     string   `json:"main_category"`
+	Currency         string   `json:"currency"`
+	Deadline         string   `json:"deadline"`
+	Launched         string   `json:"launched"`
+	State            string   `json:"state"`
+	Country          string   `json:"country"`
+	Backers          int      `json:"backers"`
+	Goal             float64  `json:"goal"`
+	GoalUSDReal      *float64 `json:"goal_usd_real"`
+	Pledged          float64  `json:"pledged"`
+	PledgedUSD       *float64 `json:"pledged_usd"`
+	PledgedUSDReal   *float64 `json:"pledged_usd_real"`
+	DurationDays     int      `json:"duration_days"`
+	FundingPct       *float64 `json:"funding_pct"`
+	GoalReporting    *float64 `json:"goal_reporting"`
+	PledgedReporting *float64 `json:"pledged_reporting"`
+}
+
+// jsonSink writes kickstarts as newline-delimited JSON objects, one at a time.
+type jsonSink struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONSink(w io.Writer) *jsonSink {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	// Names such as "Film & Video" are not meant for HTML.
+	enc.SetEscapeHTML(false)
+	return &jsonSink{w: bw, enc: enc}
+}
+
+func (s *jsonSink) Write(k Kickstart) error {
+	rec := jsonRecord{
+		KickstarterID: k.Product.KickstarterID,
+		Name:          k.Product.Name,
+		Category:      k.Category.Name,
+		MainCategory:  k.MainCategory.Name,
+		Currency:      k.Currency.Type,
+		Deadline:      k.Date.Deadline.Format(deadlineLayout),
+		Launched:      k.Date.Launched.Format(launchedLayout),
+		State:         k.State.State,
+		Country:       k.Area.Country,
+		Backers:       k.Backers,
+		Goal:          k.Goal,
+		Pledged:       k.Pledged,
+		DurationDays:  k.DurationDays,
+	}
+	rec.GoalUSDReal = nullFloatPtr(k.GoalUSDReal)
+	rec.PledgedUSD = nullFloatPtr(k.PledgedUSD)
+	rec.PledgedUSDReal = nullFloatPtr(k.PledgedUSDReal)
+	rec.FundingPct = nullFloatPtr(k.FundingPct)
+	rec.GoalReporting = nullFloatPtr(k.GoalReporting)
+	rec.PledgedReporting = nullFloatPtr(k.PledgedReporting)
+	return s.enc.Encode(rec)
+}
+
+// nullFloatPtr returns a pointer to the value of f or nil if it is NULL, which
+// encodes as a JSON null.
+func nullFloatPtr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+// Flush writes any buffered records.
+func (s *jsonSink) Flush() error {
+	return s.w.Flush()
+}
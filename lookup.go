@@ -0,0 +1,418 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// lookupTable is a dimension table whose rows are deduplicated by their
+// natural key: each distinct combination of the values of the key columns is
+// stored once and shared by every fact row that has it.
+type lookupTable struct {
+	table string
+	// columns are the natural key of the table, which has a unique
+	// constraint on them.
+	columns  []string
+	fkColumn string
+	// refs are the lookup tables that key columns of the table reference,
+	// whose ids values needs.
+	refs []string
+	// values returns the values of columns for k, given the ids of the rows
+	// of refs that k has.
+	values func(k Kickstart, ids map[string]int64) []interface{}
+}
+
+var lookupTables = []lookupTable{
+	{"main_categories", []string{"name"}, "main_category_id", nil, func(k Kickstart, _ map[string]int64) []interface{} {
+		return []interface{}{k.MainCategory.Name}
+	}},
+	// A category name such as Software can appear under more than one main
+	// category, and is a different category under each.
+	{"categories", []string{"name", "main_category_id"}, "category_id", []string{"main_categories"}, func(k Kickstart, ids map[string]int64) []interface{} {
+		return []interface{}{k.Category.Name, ids["main_categories"]}
+	}},
+	{"currencies", []string{"type"}, "currency_id", nil, func(k Kickstart, _ map[string]int64) []interface{} {
+		return []interface{}{k.Currency.Type}
+	}},
+	{"states", []string{"state"}, "state_id", nil, func(k Kickstart, _ map[string]int64) []interface{} {
+		return []interface{}{k.State.State}
+	}},
+	{"areas", []string{"country"}, "area_id", nil, func(k Kickstart, _ map[string]int64) []interface{} {
+		return []interface{}{k.Area.Country}
+	}},
+}
+
+func findLookupTable(name string) (lookupTable, bool) {
+	for _, lt := range lookupTables {
+		if lt.table == name {
+			return lt, true
+		}
+	}
+	return lookupTable{}, false
+}
+
+// lookupKey returns the key that the values of the natural key of a row are
+// cached by.
+func lookupKey(values []interface{}) string {
+	if len(values) == 1 {
+		if s, ok := values[0].(string); ok {
+			return s
+		}
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// scanLookupRow scans a row of the id and the key columns of a lookup table
+// and returns the id and the key of the row.
+func scanLookupRow(rows *sql.Rows, columns int) (int64, string, error) {
+	var id int64
+	values := make([]string, columns)
+	dest := []interface{}{&id}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, "", err
+	}
+	return id, strings.Join(values, "\x00"), nil
+}
+
+// lookupOptions bound the memory a lookupCache takes for a lookup table that
+// turns out to have far more distinct values than expected.
+type lookupOptions struct {
+	// maxValues is the number of values of a lookup table above which a
+	// warning is logged. Zero means no limit.
+	maxValues int
+	// fallback stops caching the values of a lookup table once it has
+	// maxValues of them. The values that are not cached are looked up in
+	// the database each time instead.
+	fallback bool
+	// stats, if not nil, counts the lookups of the cache.
+	stats lookupStats
+}
+
+// lookupStats counts, by lookup table, how many rows looked up their value in
+// a lookupCache and how often the value was already there. Its counters are
+// atomic so that the workers of a parallel load, which share a cache, can
+// count at once. The maps of the cache itself are not locked, see
+// lookupCache.
+type lookupStats map[string]*lookupCounts
+
+type lookupCounts struct {
+	// rows are the rows that looked up a value and hits the rows whose
+	// value was cached.
+	rows, hits atomic.Int64
+	// misses are the values that were looked up in or inserted into the
+	// database. A value inserted before the rows that have it is a miss,
+	// and the rows then hit it.
+	misses atomic.Int64
+}
+
+func newLookupStats() lookupStats {
+	s := make(lookupStats)
+	for _, lt := range lookupTables {
+		s[lt.table] = &lookupCounts{}
+	}
+	return s
+}
+
+// add adds to the counts of table. A nil lookupStats counts nothing.
+func (s lookupStats) add(table string, rows, hits, misses int) {
+	if s == nil {
+		return
+	}
+	c := s[table]
+	c.rows.Add(int64(rows))
+	c.hits.Add(int64(hits))
+	c.misses.Add(int64(misses))
+}
+
+// addAll adds the counts of o to s.
+func (s lookupStats) addAll(o lookupStats) {
+	for table, c := range o {
+		s.add(table, int(c.rows.Load()), int(c.hits.Load()), int(c.misses.Load()))
+	}
+}
+
+// lookupCache remembers the ids of the rows of the lookup tables by their
+// lookupKey so that each distinct value is only looked up or inserted once per
+// load. It is not safe for concurrent use. The workers of a parallel load
+// share one only because preloadLookups caches every value of their rows, or
+// marks the table full, before they start, so that they only read it.
+type lookupCache struct {
+	ids  map[string]map[string]int64
+	opts lookupOptions
+	// full are the lookup tables with more than opts.maxValues values.
+	full map[string]bool
+}
+
+func newLookupCache() *lookupCache {
+	return &lookupCache{ids: make(map[string]map[string]int64), full: make(map[string]bool)}
+}
+
+// store caches the id of the row of table whose key is value, unless the
+// table is full and falls back to the database.
+func (c *lookupCache) store(table, value string, id int64) {
+	ids := c.ids[table]
+	if ids == nil {
+		ids = make(map[string]int64)
+		c.ids[table] = ids
+	}
+	if _, ok := ids[value]; ok {
+		return
+	}
+	if c.opts.maxValues > 0 && len(ids) >= c.opts.maxValues && !c.full[table] {
+		c.full[table] = true
+		slog.Warn("Lookup table has more distinct values than --max-lookup-values", "table", table, "max", c.opts.maxValues, "fallback", c.opts.fallback)
+	}
+	if c.fallingBack(table) {
+		return
+	}
+	ids[value] = id
+}
+
+// fallingBack reports whether the values of table that are not cached are
+// looked up in the database.
+func (c *lookupCache) fallingBack(table string) bool {
+	return c.full[table] && c.opts.fallback
+}
+
+// id returns the id of the row of lt that k has, inserting it and the rows of
+// lt.refs it references if they do not exist yet.
+func (c *lookupCache) id(ctx context.Context, db execer, d dialect, lt lookupTable, k Kickstart) (int64, error) {
+	ids, err := c.refIDs(ctx, db, d, lt, k)
+	if err != nil {
+		return 0, err
+	}
+	return c.getOrInsert(ctx, db, d, lt, lt.values(k, ids)...)
+}
+
+// refIDs returns the ids of the rows of lt.refs that k has, inserting them if
+// they do not exist yet. It returns nil if lt has no refs.
+func (c *lookupCache) refIDs(ctx context.Context, db execer, d dialect, lt lookupTable, k Kickstart) (map[string]int64, error) {
+	var ids map[string]int64
+	for _, ref := range lt.refs {
+		rlt, _ := findLookupTable(ref)
+		refs, err := c.refIDs(ctx, db, d, rlt, k)
+		if err != nil {
+			return nil, err
+		}
+		id, _, err := c.lookup(ctx, db, d, rlt, rlt.values(k, refs))
+		if err != nil {
+			return nil, fmt.Errorf("inserting into %s: %w", ref, err)
+		}
+		if ids == nil {
+			ids = make(map[string]int64)
+		}
+		ids[ref] = id
+	}
+	return ids, nil
+}
+
+// getOrInsert returns the id of the row of lt whose key columns have values
+// for a row of the load. The row of lt is inserted if it does not exist yet.
+func (c *lookupCache) getOrInsert(ctx context.Context, db execer, d dialect, lt lookupTable, values ...interface{}) (int64, error) {
+	id, cached, err := c.lookup(ctx, db, d, lt, values)
+	if err != nil {
+		return 0, err
+	}
+	if cached {
+		c.opts.stats.add(lt.table, 1, 1, 0)
+	} else {
+		c.opts.stats.add(lt.table, 1, 0, 1)
+	}
+	return id, nil
+}
+
+// lookup is getOrInsert without counting the lookup in the stats of c. It also
+// reports whether the id was cached.
+func (c *lookupCache) lookup(ctx context.Context, db execer, d dialect, lt lookupTable, values []interface{}) (int64, bool, error) {
+	key := lookupKey(values)
+	if id, ok := c.ids[lt.table][key]; ok {
+		return id, true, nil
+	}
+
+	var id int64
+	conds := make([]string, len(lt.columns))
+	for i, col := range lt.columns {
+		conds[i] = col + " = ?"
+	}
+	query := fmt.Sprintf("SELECT id FROM %s WHERE %s LIMIT 1", d.table(lt.table), strings.Join(conds, " AND "))
+	err := db.QueryRowContext(ctx, d.rebind(query), values...).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		if id, err = d.insertID(ctx, db, d.insert(lt.table, 1, lt.columns...), values...); err != nil {
+			return 0, false, err
+		}
+	case err != nil:
+		return 0, false, err
+	}
+	c.store(lt.table, key, id)
+	return id, false, nil
+}
+
+// insertValues inserts the values of lt in kk that c does not have yet with
+// multi-row INSERT statements of up to batchSize values each, and adds their
+// ids to c. This inserts a whole lookup table in a few statements instead of
+// one per value, before the fact rows that reference it are loaded.
+func (c *lookupCache) insertValues(ctx context.Context, tx *sql.Tx, d dialect, lt lookupTable, kk []Kickstart, batchSize int) error {
+	ids := c.ids[lt.table]
+	if ids == nil {
+		ids = make(map[string]int64)
+		c.ids[lt.table] = ids
+	}
+	var values [][]interface{}
+	seen := make(map[string]bool)
+	for _, k := range kk {
+		if c.opts.fallback && c.opts.maxValues > 0 && len(ids)+len(values) >= c.opts.maxValues {
+			// The rest would not be cached, so they are left to be looked
+			// up one at a time with the fact rows instead of being held
+			// here.
+			break
+		}
+		refIDs, err := c.refIDs(ctx, tx, d, lt, k)
+		if err != nil {
+			return err
+		}
+		v := lt.values(k, refIDs)
+		key := lookupKey(v)
+		if _, ok := ids[key]; ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		values = append(values, v)
+	}
+	cols := strings.Join(lt.columns, ", ")
+	for start := 0; start < len(values); start += batchSize {
+		end := start + batchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		batch := values[start:end]
+		var args []interface{}
+		for _, v := range batch {
+			args = append(args, v...)
+		}
+		if _, err := tx.ExecContext(ctx, d.insertMissing(lt.table, len(batch), lt.columns...), args...); err != nil {
+			return err
+		}
+		where := lt.columns[0] + " IN " + questionPlaceholders.placeholders(len(batch), 1)
+		if len(lt.columns) > 1 {
+			where = "(" + cols + ") IN (" + questionPlaceholders.placeholders(len(lt.columns), len(batch)) + ")"
+		}
+		query := fmt.Sprintf("SELECT id, %s FROM %s WHERE %s", cols, d.table(lt.table), where)
+		rows, err := tx.QueryContext(ctx, d.rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			id, key, err := scanLookupRow(rows, len(lt.columns))
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			c.store(lt.table, key, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// A collation that ignores case or accents, as MySQL's does by
+		// default, matches a value with a row stored with a different
+		// spelling, which only getOrInsert looks up.
+		for _, v := range batch {
+			if _, ok := c.ids[lt.table][lookupKey(v)]; !ok && !c.fallingBack(lt.table) {
+				if _, _, err := c.lookup(ctx, tx, d, lt, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	c.opts.stats.add(lt.table, 0, 0, len(values))
+	if len(values) != 0 {
+		slog.Debug("Inserted lookup values", "table", lt.table, "values", len(values), "statements", (len(values)+batchSize-1)/batchSize)
+	}
+	return nil
+}
+
+// insertLookupValues inserts the lookup values of kk that c does not have yet
+// into every lookup table, see insertValues.
+func (c *lookupCache) insertLookupValues(ctx context.Context, tx *sql.Tx, d dialect, kk []Kickstart, batchSize int) error {
+	for _, lt := range lookupTables {
+		if err := c.insertValues(ctx, tx, d, lt, kk, batchSize); err != nil {
+			return fmt.Errorf("inserting into %s: %w", lt.table, err)
+		}
+	}
+	return nil
+}
+
+// preload fills c with the rows that the lookup tables already have, so that
+// a load into populated tables reuses them without looking each value up.
+func (c *lookupCache) preload(ctx context.Context, tx *sql.Tx, d dialect) error {
+	for _, lt := range lookupTables {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT id, %s FROM %s", strings.Join(lt.columns, ", "), d.table(lt.table)))
+		if err != nil {
+			return fmt.Errorf("reading %s: %v", lt.table, err)
+		}
+		for rows.Next() {
+			id, key, err := scanLookupRow(rows, len(lt.columns))
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("reading %s: %v", lt.table, err)
+			}
+			c.store(lt.table, key, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("reading %s: %v", lt.table, err)
+		}
+	}
+	return nil
+}
+
+// newLoadedLookupCache returns a cache bounded by opts that is preloaded with
+// the rows of the lookup tables.
+func newLoadedLookupCache(ctx context.Context, tx *sql.Tx, d dialect, opts lookupOptions) (*lookupCache, error) {
+	c := newLookupCache()
+	c.opts = opts
+	if err := c.preload(ctx, tx, d); err != nil {
+		return nil, err
+	}
+	slog.Debug("Preloaded lookup values", "values", c.size())
+	return c, nil
+}
+
+// logCardinality logs the number of distinct values of each lookup table that
+// c has seen. The number of a table that fell back to the database is only
+// the number that is cached.
+func (c *lookupCache) logCardinality() {
+	var attrs []any
+	var full []string
+	for _, lt := range lookupTables {
+		attrs = append(attrs, lt.table, len(c.ids[lt.table]))
+		if c.fallingBack(lt.table) {
+			full = append(full, lt.table)
+		}
+	}
+	if len(full) != 0 {
+		attrs = append(attrs, "falling_back", strings.Join(full, ","))
+	}
+	slog.Info("Lookup values", attrs...)
+}
+
+// size returns the number of values in c.
+func (c *lookupCache) size() int {
+	n := 0
+	for _, ids := range c.ids {
+		n += len(ids)
+	}
+	return n
+}
@@ -0,0 +1,260 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestInsertValues(t *testing.T) {
+	ctx := context.Background()
+	d := dialect{kind: sqliteDialect}
+	_, db := testDBConfig(t, "")
+	if err := createTables(ctx, db, d, schemaTables); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	lt, _ := findLookupTable("areas")
+	// US is already in the table, but not in the cache.
+	if _, err := newLookupCache().getOrInsert(ctx, tx, d, lt, "US"); err != nil {
+		t.Fatal(err)
+	}
+
+	var kk []Kickstart
+	for _, country := range []string{"US", "GB", "DE", "GB", "FR", "IT"} {
+		kk = append(kk, Kickstart{Area: Area{Country: country}})
+	}
+	c := newLookupCache()
+	if err := c.insertValues(ctx, tx, d, lt, kk, 2); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(c.ids["areas"]); n != 5 {
+		t.Errorf("cache has %d countries, want 5", n)
+	}
+	for country, id := range c.ids["areas"] {
+		var got string
+		if err := tx.QueryRow("SELECT country FROM areas WHERE id = ?", id).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != country {
+			t.Errorf("id %d of %s is the row of %s", id, country, got)
+		}
+	}
+	var n int
+	if err := tx.QueryRow("SELECT count(*) FROM areas").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("areas has %d rows, want 5", n)
+	}
+}
+
+func TestLookupFallback(t *testing.T) {
+	ctx := context.Background()
+	d := dialect{kind: sqliteDialect}
+	var rows []string
+	for i := 1; i <= 50; i++ {
+		rows = append(rows, testRow(map[string]string{"ID": fmt.Sprint(i), "country": fmt.Sprintf("C%02d", i)}))
+	}
+	kk := testKickstarts(t, rows...)
+	tests := []struct {
+		name       string
+		opts       lookupOptions
+		wantCached int
+	}{
+		{"no limit", lookupOptions{}, 50},
+		{"warning only", lookupOptions{maxValues: 10}, 50},
+		{"fallback", lookupOptions{maxValues: 10, fallback: true}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, db := testDBConfig(t, "")
+			if err := createTables(ctx, db, d, schemaTables); err != nil {
+				t.Fatal(err)
+			}
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			c, err := newLoadedLookupCache(ctx, tx, d, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.insertLookupValues(ctx, tx, d, kk, 4); err != nil {
+				t.Fatal(err)
+			}
+			lt, _ := findLookupTable("areas")
+			for _, k := range kk {
+				id, err := c.id(ctx, tx, d, lt, k)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got string
+				if err := tx.QueryRow("SELECT country FROM areas WHERE id = ?", id).Scan(&got); err != nil {
+					t.Fatal(err)
+				}
+				if got != k.Area.Country {
+					t.Fatalf("id %d of %s is the row of %s", id, k.Area.Country, got)
+				}
+			}
+			if n := len(c.ids["areas"]); n != tt.wantCached {
+				t.Errorf("cache has %d countries, want %d", n, tt.wantCached)
+			}
+			if want := tt.opts.maxValues > 0; c.full["areas"] != want {
+				t.Errorf("areas full = %v, want %v", c.full["areas"], want)
+			}
+			var n int
+			if err := tx.QueryRow("SELECT count(*) FROM areas").Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			if n != 50 {
+				t.Errorf("areas has %d rows, want 50", n)
+			}
+		})
+	}
+}
+
+func TestCategoriesByMainCategory(t *testing.T) {
+	rows := []string{
+		testRow(map[string]string{"ID": "1", "category": "Software", "main_category": "Technology"}),
+		testRow(map[string]string{"ID": "2", "category": "Software", "main_category": "Games"}),
+		testRow(map[string]string{"ID": "3", "category": "Software", "main_category": "Technology"}),
+	}
+	tests := []struct {
+		name   string
+		change func(c *Config)
+	}{
+		{"load", func(c *Config) {}},
+		{"stream", func(c *Config) { c.Stream = true }},
+		{"merge", func(c *Config) { c.Merge = true }},
+		{"upsert", func(c *Config) { c.Upsert = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(rows...)))
+			tt.change(&c)
+			if err := Run(context.Background(), c); err != nil {
+				t.Fatal(err)
+			}
+			if n := queryInt(t, db, "SELECT count(*) FROM categories"); n != 2 {
+				t.Errorf("categories has %d rows, want 2", n)
+			}
+			if n := queryInt(t, db, "SELECT count(DISTINCT category_id) FROM kickstarts"); n != 2 {
+				t.Errorf("kickstarts reference %d categories, want 2", n)
+			}
+			mismatched := queryInt(t, db, `SELECT count(*) FROM kickstarts k JOIN categories c ON c.id = k.category_id
+				WHERE c.main_category_id != k.main_category_id`)
+			if mismatched != 0 {
+				t.Errorf("%d kickstarts have a category of another main category", mismatched)
+			}
+		})
+	}
+}
+
+func TestCountLoaderCategories(t *testing.T) {
+	kk := testKickstarts(t,
+		testRow(map[string]string{"ID": "1", "category": "Software", "main_category": "Technology"}),
+		testRow(map[string]string{"ID": "2", "category": "Software", "main_category": "Games"}),
+		testRow(map[string]string{"ID": "3", "category": "Software", "main_category": "Technology"}),
+	)
+	var b strings.Builder
+	if err := (&countLoader{w: &b}).Load(context.Background(), kk); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\ncategories: 2\n") {
+		t.Errorf("count output is\n%s\nwant 2 categories", b.String())
+	}
+}
+
+func TestLookupStats(t *testing.T) {
+	ctx := context.Background()
+	d := dialect{kind: sqliteDialect}
+	var rows []string
+	for i, country := range []string{"US", "GB", "US", "DE", "US"} {
+		rows = append(rows, testRow(map[string]string{"ID": fmt.Sprint(i + 1), "country": country}))
+	}
+	kk := testKickstarts(t, rows...)
+	tests := []struct {
+		name string
+		load func(db *sql.DB, lookups lookupOptions) error
+		want lookupSummary
+	}{
+		{"load", func(db *sql.DB, lookups lookupOptions) error {
+			return loadData(ctx, db, d, kk, defaultLoadOrder(), 1000, 1000, lookups, false, nil, newThrottle(0), nil, func(int, int) {})
+		}, lookupSummary{Rows: 5, CacheHits: 5, CacheMisses: 3, DedupRate: 0.4}},
+		{"upsert", func(db *sql.DB, lookups lookupOptions) error {
+			return upsertData(ctx, db, d, kk, lookups, nil, newThrottle(0), nil, func(int, int) {})
+		}, lookupSummary{Rows: 5, CacheHits: 2, CacheMisses: 3, DedupRate: 0.4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, db := testDBConfig(t, "")
+			if err := createTables(ctx, db, d, schemaTables); err != nil {
+				t.Fatal(err)
+			}
+			r := newLoadReport()
+			r.lookups = newLookupStats()
+			if err := tt.load(db, lookupOptions{stats: r.lookups}); err != nil {
+				t.Fatal(err)
+			}
+			r.finish(&rowErrors{}, nil, time.Second)
+			got := r.Lookups["areas"]
+			if got.Rows != tt.want.Rows || got.CacheHits != tt.want.CacheHits || got.CacheMisses != tt.want.CacheMisses || math.Abs(got.DedupRate-tt.want.DedupRate) > 1e-9 {
+				t.Errorf("areas lookups are %+v, want %+v", got, tt.want)
+			}
+			if got := r.Lookups["states"]; got.Rows != 5 || got.CacheMisses != 1 {
+				t.Errorf("states lookups are %+v, want 5 rows and 1 miss", got)
+			}
+		})
+	}
+}
+
+func TestLookupStatsRetried(t *testing.T) {
+	stats := newLookupStats()
+	l := &SQLLoader{maxRetries: 1, lookups: lookupOptions{stats: stats}}
+	calls := 0
+	err := l.withRetries(context.Background(), func(lookups lookupOptions) error {
+		calls++
+		lookups.stats.add("areas", 5, 2, 3)
+		if calls == 1 {
+			return &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("the load was attempted %d times, want 2", calls)
+	}
+	c := stats["areas"]
+	if rows, hits, misses := c.rows.Load(), c.hits.Load(), c.misses.Load(); rows != 5 || hits != 2 || misses != 3 {
+		t.Errorf("the retried load counted %d rows, %d hits and %d misses, want those of one attempt: 5, 2 and 3", rows, hits, misses)
+	}
+}
+
+func TestReportCategories(t *testing.T) {
+	r := newLoadReport()
+	for _, k := range testKickstarts(t,
+		testRow(map[string]string{"ID": "1", "category": "Software", "main_category": "Technology"}),
+		testRow(map[string]string{"ID": "2", "category": "Software", "main_category": "Games"}),
+		testRow(map[string]string{"ID": "3", "category": "Software", "main_category": "Technology"}),
+	) {
+		r.add(k)
+	}
+	r.finish(&rowErrors{}, nil, time.Second)
+	if r.Categories != 2 {
+		t.Errorf("report has %d categories, want 2", r.Categories)
+	}
+}
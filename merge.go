@@ -0,0 +1,204 @@
+package etl
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// rowHash returns a hash of the measures and the dates of k, and of the
+// duration_days derived from them, which is stored alongside the fact row and
+// used to detect whether a row has changed between loads. The measures are
+// formatted with the same precision as their columns so that a value that
+// round-trips through the database hashes the same.
+func (k Kickstart) rowHash() string {
+	s := fmt.Sprintf("%d|%.2f|%s|%.2f|%s|%s|%s|%s|%s|%s|%d", k.Backers, k.Goal, formatNullFloat(k.GoalUSDReal), k.Pledged, formatNullFloat(k.PledgedUSD), formatNullFloat(k.PledgedUSDReal), formatNullFloat(k.GoalReporting), formatNullFloat(k.PledgedReporting), k.Date.Deadline.Format(deadlineLayout), k.Date.Launched.Format(launchedLayout), k.DurationDays)
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+type mergeResult int
+
+const (
+	mergeInserted mergeResult = iota
+	mergeUpdated
+	mergeUnchanged
+)
+
+// mergeData merges kk into the existing tables. For each kickstart, the row is
+// inserted if its natural key (the product's kickstarter_id) is new, its
+// measures are updated if the key exists but the row hash differs and nothing
+// is done if the row hash is unchanged. The dimension attributes of an existing
+// row are updated in place when they changed, see updateDimensions.
+//
+// The merge is emulated on every dialect by looking up the existing fact row
+// by its natural key and then issuing either an INSERT or an UPDATE. MySQL
+// and SQLite have no MERGE statement. Postgres has had one since version 15,
+// but it is not used so that older servers work and all dialects share one
+// path, which also has to write the dimension rows a MERGE of the fact table
+// alone could not. Like loadData, the merge happens in a single transaction.
+func mergeData(ctx context.Context, db *sql.DB, d dialect, kk []Kickstart, order []string, lookups lookupOptions, meta *loadMetadata, limit *throttle, stop *shutdown, progress ProgressFunc) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cache, err := newLoadedLookupCache(ctx, tx, d, lookups)
+	if err != nil {
+		return err
+	}
+	var inserted, updated, unchanged, dimensions int
+	for i, k := range kk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stop.stopRequested() {
+			if err := recordLoad(ctx, tx, d, meta, i); err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			slog.Info("Stopped merging", "rows", i, "total", len(kk))
+			return ErrInterrupted
+		}
+		if i%progressInterval == 0 {
+			progress(i, len(kk))
+		}
+		limit.wait()
+
+		res, n, err := mergeKickstart(ctx, tx, d, k, order, cache)
+		if err != nil {
+			return err
+		}
+		dimensions += n
+		switch res {
+		case mergeInserted:
+			inserted++
+		case mergeUpdated:
+			updated++
+		case mergeUnchanged:
+			unchanged++
+		}
+	}
+	if err := recordLoad(ctx, tx, d, meta, len(kk)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("Merged data", "rows", len(kk), "inserted", inserted, "updated", updated, "unchanged", unchanged, "dimension_values_updated", dimensions)
+	cache.logCardinality()
+	return nil
+}
+
+// mergeKickstart merges k and returns what was done with its fact row along
+// with the number of dimension values that were updated.
+func mergeKickstart(ctx context.Context, db execer, d dialect, k Kickstart, order []string, cache *lookupCache) (mergeResult, int, error) {
+	query := fmt.Sprintf(`
+		SELECT k.id, k.row_hash
+		FROM %s k
+		JOIN %s p ON k.product_id = p.id
+		WHERE p.kickstarter_id = ?`, d.table("kickstarts"), d.table("products"))
+	var (
+		id      int64
+		oldHash sql.NullString
+	)
+	err := db.QueryRowContext(ctx, d.rebind(query), k.Product.KickstarterID).Scan(&id, &oldHash)
+	if err == sql.ErrNoRows {
+		if err := insertKickstart(ctx, db, d, k, order, cache); err != nil {
+			return 0, 0, err
+		}
+		return mergeInserted, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("looking up kickstarter_id %d: %v", k.Product.KickstarterID, err)
+	}
+
+	dimensions, err := updateDimensions(ctx, db, d, id, k, cache)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if oldHash.Valid && oldHash.String == k.rowHash() {
+		return mergeUnchanged, dimensions, nil
+	}
+
+	// Every column of the fact row is set except its foreign keys, which
+	// updateDimensions has already pointed at the current values, so that
+	// the derived columns are updated along with the measures.
+	var (
+		sets []string
+		args []interface{}
+	)
+	for i, v := range kickstartArgs(k, nil) {
+		c := kickstartColumns[i]
+		if strings.HasSuffix(c, "_id") {
+			continue
+		}
+		sets = append(sets, c+" = ?")
+		args = append(args, v)
+	}
+	updateKickstarts := fmt.Sprintf("UPDATE %s SET %s WHERE id = ?", d.table("kickstarts"), strings.Join(sets, ", "))
+	_, err = db.ExecContext(ctx, d.rebind(updateKickstarts), append(args, id)...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("updating kickstart %d: %v", id, err)
+	}
+	return mergeUpdated, dimensions, nil
+}
+
+// updateDimensions updates the dimension values of the fact row with the
+// given id that differ from k, so that a value corrected in a later file
+// replaces the old one instead of being ignored (a slowly changing dimension
+// of type 1). The product and the dates of the fact row are its own so they
+// are updated in place, like upsertKickstart does. Lookup rows are shared with
+// other fact rows so instead the fact row is pointed at the row of the new
+// value. It returns the number of values that were updated.
+func updateDimensions(ctx context.Context, db execer, d dialect, id int64, k Kickstart, cache *lookupCache) (int, error) {
+	updateProduct := `UPDATE ` + d.table("products") + ` SET name = ?
+		WHERE id = (SELECT product_id FROM ` + d.table("kickstarts") + ` WHERE id = ?) AND ` + d.isDistinct("name", "?")
+	res, err := db.ExecContext(ctx, d.rebind(updateProduct), k.Product.Name, id, k.Product.Name)
+	if err != nil {
+		return 0, fmt.Errorf("updating product of kickstart %d: %v", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	updated := int(n)
+
+	updateDate := `UPDATE ` + d.table("dates") + ` SET deadline = ?, launched = ?
+		WHERE id = (SELECT date_id FROM ` + d.table("kickstarts") + ` WHERE id = ?) AND (` + d.isDistinct("deadline", "?") + ` OR ` + d.isDistinct("launched", "?") + `)`
+	deadline, launched := d.timeArg(k.Date.Deadline), d.timeArg(k.Date.Launched)
+	res, err = db.ExecContext(ctx, d.rebind(updateDate), deadline, launched, id, deadline, launched)
+	if err != nil {
+		return 0, fmt.Errorf("updating date of kickstart %d: %v", id, err)
+	}
+	if n, err = res.RowsAffected(); err != nil {
+		return 0, err
+	}
+	updated += int(n)
+
+	for _, lt := range lookupTables {
+		valueID, err := cache.id(ctx, db, d, lt, k)
+		if err != nil {
+			return 0, fmt.Errorf("looking up %s: %v", lt.table, err)
+		}
+		query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ? AND %s", d.table("kickstarts"), lt.fkColumn, d.isDistinct(lt.fkColumn, "?"))
+		res, err := db.ExecContext(ctx, d.rebind(query), valueID, id, valueID)
+		if err != nil {
+			return 0, fmt.Errorf("updating %s of kickstart %d: %v", lt.fkColumn, id, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		updated += int(n)
+	}
+	return updated, nil
+}
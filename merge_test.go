@@ -0,0 +1,168 @@
+package etl
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMergeKickstart(t *testing.T) {
+	ctx := context.Background()
+	d := dialect{kind: sqliteDialect}
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(
+		testRow(map[string]string{"ID": "1"}),
+		testRow(map[string]string{"ID": "2"}),
+	)))
+	if err := Run(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	kk := testKickstarts(t,
+		testRow(map[string]string{"ID": "1"}),
+		testRow(map[string]string{"ID": "2", "backers": "20", "pledged": "3000.00"}),
+		testRow(map[string]string{"ID": "3"}),
+	)
+	want := []mergeResult{mergeUnchanged, mergeUpdated, mergeInserted}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	cache, err := newLoadedLookupCache(ctx, tx, d, lookupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, k := range kk {
+		res, _, err := mergeKickstart(ctx, tx, d, k, defaultLoadOrder(), cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != want[i] {
+			t.Errorf("merge of kickstarter_id %d returned %d, want %d", k.Product.KickstarterID, res, want[i])
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := queryInt(t, db, "SELECT count(*) FROM kickstarts"); n != 3 {
+		t.Errorf("kickstarts has %d rows, want 3", n)
+	}
+	backers := queryInt(t, db, `SELECT k.backers FROM kickstarts k JOIN products p ON p.id = k.product_id
+		WHERE p.kickstarter_id = 2`)
+	if backers != 20 {
+		t.Errorf("updated kickstart has %d backers, want 20", backers)
+	}
+	pledged := queryInt(t, db, `SELECT k.pledged FROM kickstarts k JOIN products p ON p.id = k.product_id
+		WHERE p.kickstarter_id = 2`)
+	if pledged != 3000 {
+		t.Errorf("updated kickstart pledged %d, want 3000", pledged)
+	}
+}
+
+func TestMergeCorrectsDimensions(t *testing.T) {
+	ctx := context.Background()
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(
+		testRow(map[string]string{"ID": "1", "name": "Greting From Earth", "country": "GB"}),
+		testRow(map[string]string{"ID": "2", "country": "GB"}),
+	)))
+	if err := Run(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	c.Input = writeTestFile(t, "in.csv", testCSV(
+		testRow(map[string]string{"ID": "1", "name": "Greeting From Earth", "country": "US"}),
+	))
+	c.Merge = true
+	if err := Run(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	var name, country string
+	err := db.QueryRow(`SELECT p.name, a.country FROM kickstarts k
+		JOIN products p ON p.id = k.product_id
+		JOIN areas a ON a.id = k.area_id
+		WHERE p.kickstarter_id = 1`).Scan(&name, &country)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Greeting From Earth" || country != "US" {
+		t.Errorf("merged kickstart has name %q and country %s, want the corrected ones", name, country)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM products"); n != 2 {
+		t.Errorf("products has %d rows, want 2 as the name is updated in place", n)
+	}
+	other := queryInt(t, db, `SELECT count(*) FROM kickstarts k JOIN areas a ON a.id = k.area_id WHERE a.country = 'GB'`)
+	if other != 1 {
+		t.Errorf("%d kickstarts are in GB, want the other one to keep it", other)
+	}
+}
+
+func TestMergeCorrectsDates(t *testing.T) {
+	ctx := context.Background()
+	d := dialect{kind: sqliteDialect}
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(testRow(map[string]string{"ID": "1"}))))
+	if err := Run(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	kk := testKickstarts(t, testRow(map[string]string{"ID": "1", "deadline": "2017-11-15"}))
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	cache, err := newLoadedLookupCache(ctx, tx, d, lookupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, dimensions, err := mergeKickstart(ctx, tx, d, kk[0], defaultLoadOrder(), cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != mergeUpdated || dimensions != 1 {
+		t.Errorf("merge of a corrected deadline returned %d with %d dimension values updated, want %d with 1", res, dimensions, mergeUpdated)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var deadline string
+	var duration int
+	err = db.QueryRow(`SELECT date(d.deadline), k.duration_days FROM kickstarts k
+		JOIN dates d ON d.id = k.date_id`).Scan(&deadline, &duration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deadline != "2017-11-15" || duration != 74 {
+		t.Errorf("merged kickstart has deadline %s and runs for %d days, want 2017-11-15 and 74", deadline, duration)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM dates"); n != 1 {
+		t.Errorf("dates has %d rows, want the date updated in place", n)
+	}
+}
+
+func TestRowHash(t *testing.T) {
+	k := testKickstarts(t, testRow(nil))[0]
+	tests := []struct {
+		name   string
+		change func(k *Kickstart)
+	}{
+		{"backers", func(k *Kickstart) { k.Backers++ }},
+		{"pledged", func(k *Kickstart) { k.Pledged += 0.01 }},
+		{"deadline", func(k *Kickstart) { k.Date.Deadline = k.Date.Deadline.AddDate(0, 0, 1) }},
+		{"launched", func(k *Kickstart) { k.Date.Launched = k.Date.Launched.Add(time.Second) }},
+		{"duration_days", func(k *Kickstart) { k.DurationDays++ }},
+	}
+	for _, tt := range tests {
+		changed := k
+		tt.change(&changed)
+		if changed.rowHash() == k.rowHash() {
+			t.Errorf("a change of %s leaves the row hash unchanged", tt.name)
+		}
+	}
+	same := k
+	same.Pledged += 0.001
+	if same.rowHash() != k.rowHash() {
+		t.Error("a change below the precision of pledged changes the row hash")
+	}
+}
@@ -0,0 +1,106 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// loadMetadataTable records the lineage of every load: which file and which
+// dataset version it came from, when it happened and how many rows it loaded.
+var loadMetadataTable = table{
+	Name: "load_metadata",
+	Columns: []column{
+		idColumn(),
+		{Name: "file_name", Type: "VARCHAR(255)"},
+		{Name: "file_size", Type: "BIGINT"},
+		{Name: "file_checksum", Type: "VARCHAR(80)"},
+		{Name: "file_modtime", Type: "DATETIME"},
+		{Name: "source_version", Type: "VARCHAR(255)"},
+		{Name: "loaded_at", Type: "DATETIME"},
+		{Name: "row_count", Type: "INT"},
+	},
+}
+
+// loadMetadata is a row of load_metadata. The size, checksum and modification
+// time of an input that is not a file, such as stdin, are NULL.
+type loadMetadata struct {
+	FileName      string
+	FileSize      sql.NullInt64
+	FileChecksum  sql.NullString
+	FileModTime   sql.NullTime
+	SourceVersion string
+	LoadedAt      time.Time
+	RowCount      int
+}
+
+// newLoadMetadata describes a load of the file at path. The checksum is
+// computed unless it is already known. The file name of a load from stdin is
+// stdin and that of a URL is the URL.
+func newLoadMetadata(path, checksum, sourceVersion string) (loadMetadata, error) {
+	if path == stdinInput {
+		return loadMetadata{FileName: "stdin", SourceVersion: sourceVersion}, nil
+	}
+	if !isFileInput(path) {
+		return loadMetadata{FileName: truncate(path, 252), SourceVersion: sourceVersion}, nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return loadMetadata{}, err
+	}
+	if fi.IsDir() {
+		// The files of an input directory have no single size or checksum.
+		return loadMetadata{FileName: truncate(filepath.Base(path), 252), SourceVersion: sourceVersion}, nil
+	}
+	if checksum == "" {
+		checksum, err = fileChecksum(path)
+		if err != nil {
+			return loadMetadata{}, err
+		}
+	}
+	return loadMetadata{
+		FileName:      filepath.Base(path),
+		FileSize:      sql.NullInt64{Int64: fi.Size(), Valid: true},
+		FileChecksum:  sql.NullString{String: checksum, Valid: true},
+		FileModTime:   sql.NullTime{Time: fi.ModTime().UTC(), Valid: true},
+		SourceVersion: sourceVersion,
+	}, nil
+}
+
+// recordLoad records in load_metadata that rows rows of the load described by
+// m were loaded. Like saveCheckpoint it must be called with the transaction of
+// the load, so that the record is committed along with the rows and a load
+// that is rolled back leaves none. It does nothing if m is nil.
+func recordLoad(ctx context.Context, db execer, d dialect, m *loadMetadata, rows int) error {
+	if m == nil {
+		return nil
+	}
+	r := *m
+	r.LoadedAt = time.Now().UTC().Round(0)
+	r.RowCount = rows
+	if err := insertLoadMetadata(ctx, db, d, r); err != nil {
+		return fmt.Errorf("recording load metadata: %v", err)
+	}
+	return nil
+}
+
+func insertLoadMetadata(ctx context.Context, db execer, d dialect, m loadMetadata) error {
+	insertLoadMetadata := `INSERT INTO ` + d.table(loadMetadataTable.Name) + ` (
+		file_name,
+		file_size,
+		file_checksum,
+		file_modtime,
+		source_version,
+		loaded_at,
+		row_count
+	) values (?, ?, ?, ?, ?, ?, ?)`
+	var modTime interface{}
+	if m.FileModTime.Valid {
+		modTime = d.timeArg(m.FileModTime.Time)
+	}
+	_, err := db.ExecContext(ctx, d.rebind(insertLoadMetadata), m.FileName, m.FileSize, m.FileChecksum, modTime, m.SourceVersion, d.timeArg(m.LoadedAt), m.RowCount)
+	return err
+}
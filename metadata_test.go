@@ -0,0 +1,78 @@
+package etl
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRecordLoadInLoadTransaction(t *testing.T) {
+	dup := testRow(map[string]string{"name": "Another name"})
+	tests := []struct {
+		name    string
+		rows    []string
+		wantErr bool
+		// wantRecord is the load_metadata rows that are left, as row_count.
+		wantRecord []int
+	}{
+		{"loaded", []string{testRow(nil), testRow(map[string]string{"ID": "2"})}, false, []int{2}},
+		// The repeated kickstarter_id fails the unique index of products, so
+		// the load is rolled back along with its metadata.
+		{"rolled back", []string{testRow(nil), dup}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			d := dialect{kind: sqliteDialect}
+			_, db := testDBConfig(t, "")
+			if err := createTables(ctx, db, d, schemaTables); err != nil {
+				t.Fatal(err)
+			}
+			meta := loadMetadata{FileName: "in.csv", SourceVersion: "test"}
+			err := loadData(ctx, db, d, testKickstarts(t, tt.rows...), defaultLoadOrder(), 1000, 1000, lookupOptions{}, false, &meta, newThrottle(0), nil, func(int, int) {})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadData returned %v, want error %v", err, tt.wantErr)
+			}
+			rows, err := db.Query("SELECT row_count FROM load_metadata")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var got []int
+			for rows.Next() {
+				var n int
+				if err := rows.Scan(&n); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, n)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.wantRecord) {
+				t.Errorf("load_metadata row counts = %v, want %v", got, tt.wantRecord)
+			}
+		})
+	}
+}
+
+func TestSQLiteTimes(t *testing.T) {
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(testRow(nil))))
+	if err := Run(context.Background(), c); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"SELECT count(*) FROM dates WHERE date(deadline) = '2017-11-01'", 1},
+		{"SELECT count(*) FROM dates WHERE datetime(launched) = '2017-09-02 04:43:57'", 1},
+		{"SELECT count(*) FROM load_metadata WHERE datetime(loaded_at) IS NOT NULL AND datetime(file_modtime) IS NOT NULL", 1},
+		{"SELECT count(*) FROM etl_checkpoint WHERE datetime(saved_at) IS NOT NULL", 1},
+	}
+	for _, tt := range tests {
+		if got := queryInt(t, db, tt.query); got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package etl
+
+import (
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRecord is a kickstart as written by parquetSink. Its columns are
+// named after the columns of the cleaned CSV; amounts are doubles and dates
+// are timestamps.
+type parquetRecord struct {
+	KickstarterID    int64     `parquet:"kickstarter_id"`
+	Name             string    `parquet:"name"`
+	Category         string    `parquet:"category,dict"`
+	MainCategory     string    `parquet:"main_category,dict"`
+	Currency         string    `parquet:"currency,dict"`
+	Deadline         time.Time `parquet:"deadline,timestamp(millisecond)"`
+	Launched         time.Time `parquet:"launched,timestamp(millisecond)"`
+	State            string    `parquet:"state,dict"`
+	Country          string    `parquet:"country,dict"`
+	Backers          int64     `parquet:"backers"`
+	Goal             float64   `parquet:"goal"`
+	GoalUSDReal      *float64  `parquet:"goal_usd_real,optional"`
+	Pledged          float64   `parquet:"pledged"`
+	PledgedUSD       *float64  `parquet:"pledged_usd,optional"`
+	PledgedUSDReal   *float64  `parquet:"pledged_usd_real,optional"`
+	DurationDays     int64     `parquet:"duration_days"`
+	FundingPct       *float64  `parquet:"funding_pct,optional"`
+	GoalReporting    *float64  `parquet:"goal_reporting,optional"`
+	PledgedReporting *float64  `parquet:"pledged_reporting,optional"`
+}
+
+// parquetSink writes kickstarts to a Parquet file. The rows are buffered into
+// row groups and the file is only complete once Flush writes its footer.
+type parquetSink struct {
+	w *parquet.GenericWriter[parquetRecord]
+}
+
+func newParquetSink(w io.Writer) *parquetSink {
+	return &parquetSink{w: parquet.NewGenericWriter[parquetRecord](w)}
+}
+
+func (s *parquetSink) Write(k Kickstart) error {
+	rec := parquetRecord{
+		KickstarterID:    k.Product.KickstarterID,
+		Name:             k.Product.Name,
+		Category:         k.Category.Name,
+		MainCategory:     k.MainCategory.Name,
+		Currency:         k.Currency.Type,
+		Deadline:         k.Date.Deadline,
+		Launched:         k.Date.Launched,
+		State:            k.State.State,
+		Country:          k.Area.Country,
+		Backers:          int64(k.Backers),
+		Goal:             k.Goal,
+		GoalUSDReal:      nullFloatPtr(k.GoalUSDReal),
+		Pledged:          k.Pledged,
+		PledgedUSD:       nullFloatPtr(k.PledgedUSD),
+		PledgedUSDReal:   nullFloatPtr(k.PledgedUSDReal),
+		DurationDays:     int64(k.DurationDays),
+		FundingPct:       nullFloatPtr(k.FundingPct),
+		GoalReporting:    nullFloatPtr(k.GoalReporting),
+		PledgedReporting: nullFloatPtr(k.PledgedReporting),
+	}
+	_, err := s.w.Write([]parquetRecord{rec})
+	return err
+}
+
+// Flush writes the buffered rows and the footer of the file.
+func (s *parquetSink) Flush() error {
+	return s.w.Close()
+}
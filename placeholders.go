@@ -0,0 +1,50 @@
+package etl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// placeholderStyle is the syntax a database driver uses for query parameters.
+type placeholderStyle int
+
+const (
+	// questionPlaceholders are used by MySQL and SQLite: ?, ?, ?
+	questionPlaceholders placeholderStyle = iota
+	// dollarPlaceholders are used by PostgreSQL: $1, $2, $3
+	dollarPlaceholders
+)
+
+// placeholders returns the placeholders for rows rows of cols columns each,
+// numbered consecutively where the style needs it, e.g. "(?, ?), (?, ?)" or
+// "($1, $2), ($3, $4)".
+func (s placeholderStyle) placeholders(cols, rows int) string {
+	var b strings.Builder
+	n := 0
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for c := 0; c < cols; c++ {
+			if c > 0 {
+				b.WriteString(", ")
+			}
+			n++
+			switch s {
+			case dollarPlaceholders:
+				fmt.Fprintf(&b, "$%d", n)
+			default:
+				b.WriteByte('?')
+			}
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// insert returns an INSERT statement for rows rows into the given columns of
+// table.
+func (s placeholderStyle) insert(table string, rows int, cols ...string) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) values %s", table, strings.Join(cols, ", "), s.placeholders(len(cols), rows))
+}
@@ -0,0 +1,65 @@
+package etl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		kind       dialectKind
+		cols, rows int
+		want       string
+	}{
+		{mysqlDialect, 1, 1, "(?)"},
+		{mysqlDialect, 3, 1, "(?, ?, ?)"},
+		{mysqlDialect, 3, 2, "(?, ?, ?), (?, ?, ?)"},
+		{sqliteDialect, 1, 1, "(?)"},
+		{sqliteDialect, 3, 1, "(?, ?, ?)"},
+		{sqliteDialect, 2, 3, "(?, ?), (?, ?), (?, ?)"},
+		{postgresDialect, 1, 1, "($1)"},
+		{postgresDialect, 3, 1, "($1, $2, $3)"},
+		{postgresDialect, 3, 2, "($1, $2, $3), ($4, $5, $6)"},
+		{postgresDialect, 1, 3, "($1), ($2), ($3)"},
+	}
+	for _, tt := range tests {
+		d := dialect{kind: tt.kind}
+		if got := d.placeholders().placeholders(tt.cols, tt.rows); got != tt.want {
+			t.Errorf("%s placeholders(%d, %d) = %q, want %q", d.driverName(), tt.cols, tt.rows, got, tt.want)
+		}
+	}
+}
+
+func TestPlaceholdersLargeBatch(t *testing.T) {
+	cols, rows := len(kickstartColumns), 1000
+	for _, kind := range []dialectKind{mysqlDialect, postgresDialect, sqliteDialect} {
+		d := dialect{kind: kind}
+		got := d.placeholders().placeholders(cols, rows)
+		if n := strings.Count(got, "("); n != rows {
+			t.Errorf("%s placeholders have %d rows, want %d", d.driverName(), n, rows)
+		}
+		if kind == postgresDialect {
+			last := fmt.Sprintf("$%d)", cols*rows)
+			if !strings.HasSuffix(got, last) || strings.Contains(got, "?") {
+				t.Errorf("postgres placeholders end with %q, want %q", got[len(got)-10:], last)
+			}
+			continue
+		}
+		if n := strings.Count(got, "?"); n != cols*rows {
+			t.Errorf("%s placeholders have %d parameters, want %d", d.driverName(), n, cols*rows)
+		}
+	}
+}
+
+func TestDialectInsert(t *testing.T) {
+	d := dialect{kind: postgresDialect, prefix: "ks_"}
+	got := d.insert("countries", 2, "name", "code")
+	want := "INSERT INTO ks_countries (name, code) values ($1, $2), ($3, $4)"
+	if got != want {
+		t.Errorf("insert = %q, want %q", got, want)
+	}
+	if got := d.rebind("SELECT id FROM t WHERE a = ? AND b = ?"); got != "SELECT id FROM t WHERE a = $1 AND b = $2" {
+		t.Errorf("rebind = %q", got)
+	}
+}
@@ -0,0 +1,46 @@
+package etl
+
+import (
+	"log/slog"
+	"time"
+)
+
+// progressInterval is the number of rows between the reports of the progress
+// of a load.
+const progressInterval = 10000
+
+// ProgressFunc is called every progressInterval rows of a load, and once more
+// when it is done, with the number of rows loaded so far and the total.
+type ProgressFunc func(done, total int)
+
+// progressSmoothing is the weight of the latest interval in the rolling rate
+// of logProgress.
+const progressSmoothing = 0.3
+
+// logProgress returns a ProgressFunc that logs msg at debug level with a
+// rolling rows per second rate and an estimate of the time remaining.
+func logProgress(msg string) ProgressFunc {
+	var (
+		last     time.Time
+		lastDone int
+		rate     float64
+	)
+	return func(done, total int) {
+		now := time.Now()
+		if !last.IsZero() && done > lastDone {
+			r := float64(done-lastDone) / now.Sub(last).Seconds()
+			if rate == 0 {
+				rate = r
+			} else {
+				rate = progressSmoothing*r + (1-progressSmoothing)*rate
+			}
+		}
+		last, lastDone = now, done
+
+		var remaining time.Duration
+		if rate > 0 {
+			remaining = time.Duration(float64(total-done) / rate * float64(time.Second)).Round(time.Second)
+		}
+		slog.Debug(msg, "rows", done, "total", total, "rows_per_second", int(rate), "remaining", remaining)
+	}
+}
@@ -0,0 +1,105 @@
+package etl
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// exchangeRates convert amounts to the reporting currency. They map the code
+// of a currency, as it appears in the dataset, to the value of one unit of it
+// in the reporting currency.
+type exchangeRates map[string]float64
+
+// loadRates reads the exchange rates from path. A .json file holds an object
+// of currency codes to rates, e.g. {"USD": 1, "EUR": 1.08}, any other file is
+// a CSV with a currency,rate header.
+func loadRates(path string) (exchangeRates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rates exchangeRates
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		rates, err = readJSONRates(f)
+	} else {
+		rates, err = readCSVRates(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading rates %s: %v", path, err)
+	}
+	for currency, rate := range rates {
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return nil, fmt.Errorf("reading rates %s: rate of %s must be positive, got %v", path, currency, rate)
+		}
+	}
+	return rates, nil
+}
+
+func readJSONRates(r io.Reader) (exchangeRates, error) {
+	var rates exchangeRates
+	if err := json.NewDecoder(r).Decode(&rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+func readCSVRates(r io.Reader) (exchangeRates, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || len(rows[0]) != 2 || strings.TrimSpace(rows[0][0]) != "currency" || strings.TrimSpace(rows[0][1]) != "rate" {
+		return nil, fmt.Errorf("header must be currency,rate")
+	}
+	rates := make(exchangeRates)
+	for i, row := range rows[1:] {
+		if len(row) != 2 {
+			return nil, fmt.Errorf("line %d: expected 2 fields, got %d", i+2, len(row))
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+		rates[strings.TrimSpace(row[0])] = rate
+	}
+	return rates, nil
+}
+
+// convertCurrencies sets the goal and pledged of every kickstart in kk in the
+// reporting currency and returns the number of kickstarts whose currency has
+// no rate.
+func convertCurrencies(kk []Kickstart, rates exchangeRates, warns *warnings) int {
+	missing := 0
+	for i := range kk {
+		if !convertCurrency(&kk[i], rates, warns) {
+			missing++
+		}
+	}
+	return missing
+}
+
+// convertCurrency sets the goal and pledged of k in the reporting currency and
+// reports whether its currency has a rate. Without one they are left NULL and
+// the row is flagged with a warning, rather than storing a wrong amount.
+func convertCurrency(k *Kickstart, rates exchangeRates, warns *warnings) bool {
+	rate, ok := rates[k.Currency.Type]
+	if !ok {
+		k.GoalReporting = sql.NullFloat64{}
+		k.PledgedReporting = sql.NullFloat64{}
+		warns.warn("no exchange rate", "kickstarter_id %d has currency %q which has no exchange rate", k.Product.KickstarterID, k.Currency.Type)
+		return false
+	}
+	k.GoalReporting = sql.NullFloat64{Float64: k.Goal * rate, Valid: true}
+	k.PledgedReporting = sql.NullFloat64{Float64: k.Pledged * rate, Valid: true}
+	return true
+}
@@ -0,0 +1,164 @@
+package etl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// isURLInput reports whether the input path is an http, https or s3 URL.
+func isURLInput(p string) bool {
+	return strings.HasPrefix(p, "http://") || strings.HasPrefix(p, "https://") || isS3Input(p)
+}
+
+// isS3Input reports whether the input path is an s3 URL.
+func isS3Input(p string) bool {
+	return strings.HasPrefix(p, "s3://")
+}
+
+// openURL fetches the kickstarter CSV data at the http or https URL rawURL,
+// giving up after timeout if it is not zero. The data is decompressed like an
+// input file, based on the extension of the URL or else the content type of
+// the response. A zip archive is downloaded to a temporary file first because
+// its entries can only be found with random access. Any other input is read as
+// it is downloaded, and closing it before the end, as once the rows of --limit
+// are read, closes the connection without downloading the rest.
+func openURL(ctx context.Context, rawURL string, timeout time.Duration) (io.ReadCloser, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing input URL: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching input: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("fetching input %s: %s", rawURL, resp.Status)
+	}
+
+	return openRemote(rawURL, path.Base(u.Path), resp.Header.Get("Content-Type"), resp.Body)
+}
+
+// openS3 fetches the kickstarter CSV data at the s3://bucket/key URL rawURL.
+// The credentials and region come from the standard AWS configuration chain,
+// such as the AWS_* environment variables, the shared config files or the
+// role of the instance or container. The data is decompressed like that of
+// openURL.
+func openS3(ctx context.Context, rawURL string) (io.ReadCloser, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing input URL: %v", err)
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, "", fmt.Errorf("input URL %s must be of the form s3://bucket/key", rawURL)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("loading AWS configuration: %v", err)
+	}
+	out, err := s3.NewFromConfig(cfg).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching input %s: %v", rawURL, err)
+	}
+	return openRemote(rawURL, path.Base(key), aws.ToString(out.ContentType), out.Body)
+}
+
+// openRemote returns the data of body, fetched from rawURL, decompressed by
+// the extension of name or else by contentType. It also returns the name of
+// the CSV that is read.
+func openRemote(rawURL, name, contentType string, body io.ReadCloser) (io.ReadCloser, string, error) {
+	switch remoteFormat(name, contentType) {
+	case "zip":
+		return openRemoteZip(rawURL, body)
+	case "gzip":
+		g, err := newGzipFile(rawURL, body)
+		if err != nil {
+			return nil, "", err
+		}
+		return g, strings.TrimSuffix(name, path.Ext(name)), nil
+	default:
+		return body, name, nil
+	}
+}
+
+// remoteFormat returns "zip", "gzip" or "csv" for a fetched input by the
+// extension of its name or else by its content type.
+func remoteFormat(name, contentType string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return "csv"
+	case ".zip":
+		return "zip"
+	case ".gz":
+		return "gzip"
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/zip", "application/x-zip-compressed":
+		return "zip"
+	case "application/gzip", "application/x-gzip":
+		return "gzip"
+	default:
+		return "csv"
+	}
+}
+
+// tempZipEntry is an entry of a zip archive in a temporary file which is
+// removed once the entry is closed.
+type tempZipEntry struct {
+	io.ReadCloser
+	path string
+}
+
+func (z tempZipEntry) Close() error {
+	err := z.ReadCloser.Close()
+	if rerr := os.Remove(z.path); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// openRemoteZip downloads the zip archive body fetched from rawURL to a
+// temporary file and opens the dataset in it like openZipEntry.
+func openRemoteZip(rawURL string, body io.ReadCloser) (io.ReadCloser, string, error) {
+	defer body.Close()
+	f, err := os.CreateTemp("", "kickstarter-*.zip")
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, "", fmt.Errorf("downloading %s: %v", rawURL, err)
+	}
+	r, name, err := openZipEntry(f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, "", err
+	}
+	return tempZipEntry{ReadCloser: r, path: f.Name()}, name, nil
+}
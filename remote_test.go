@@ -0,0 +1,66 @@
+package etl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitLoader loads nothing. It waits for the server of the input to stop
+// writing it and records the number of rows it wrote.
+type waitLoader struct {
+	written <-chan int
+	rows    int
+}
+
+func (l *waitLoader) Load(ctx context.Context, kk []Kickstart) error {
+	select {
+	case l.rows = <-l.written:
+		return nil
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("the input is still downloading")
+	}
+}
+
+func TestOpenURLLimit(t *testing.T) {
+	const total = 1000000
+	written := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 0
+		defer func() { written <- n }()
+		w.Header().Set("Content-Type", "text/csv")
+		if _, err := io.WriteString(w, testHeader+"\n"); err != nil {
+			return
+		}
+		row := testRow(nil) + "\n"
+		for ; n < total; n++ {
+			if _, err := io.WriteString(w, row); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	r, _, err := openURL(ctx, srv.URL+"/ks-projects.csv", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	e := newExtractor(inputOptions{format: csvInput, maxRows: 10}, nil, nil)
+	l := &waitLoader{written: written}
+	n, _, err := runStages(ctx, r, e, &KickstartTransformer{warns: newWarnings(false)}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Errorf("loaded %d rows, want 10", n)
+	}
+	if l.rows == total {
+		t.Errorf("the server wrote all %d rows, want it to stop once the input is closed", total)
+	}
+}
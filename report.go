@@ -0,0 +1,173 @@
+package etl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// loadReport summarizes a load of the database: how many rows were read,
+// loaded and skipped, how many distinct lookup values the loaded rows have,
+// how well the lookup cache deduplicated them and the range of their launch
+// dates. RowsSkipped are the rows --skip-errors skipped, the rows that were
+// read but not loaded for another reason are counted by that reason.
+type loadReport struct {
+	RowsRead          int     `json:"rows_read"`
+	RowsLoaded        int     `json:"rows_loaded"`
+	RowsSkipped       int     `json:"rows_skipped"`
+	RowsFiltered      int     `json:"rows_filtered"`
+	RowsAlreadyLoaded int     `json:"rows_already_loaded"`
+	RowsDuplicate     int     `json:"rows_duplicate"`
+	Categories        int     `json:"categories"`
+	Countries         int     `json:"countries"`
+	Currencies        int     `json:"currencies"`
+	FirstLaunched     string  `json:"first_launched,omitempty"`
+	LastLaunched      string  `json:"last_launched,omitempty"`
+	Seconds           float64 `json:"duration_seconds"`
+	RowsPerSecond     float64 `json:"rows_per_second"`
+	// The stages are only timed separately when the rows are not streamed.
+	ExtractSeconds   float64 `json:"extract_seconds,omitempty"`
+	TransformSeconds float64 `json:"transform_seconds,omitempty"`
+	LoadSeconds      float64 `json:"load_seconds,omitempty"`
+	// Lookups are the counts of the lookup cache by lookup table.
+	Lookups map[string]lookupSummary `json:"lookups,omitempty"`
+
+	times stageTimes
+	// lookups, if not nil, are the stats of the lookup cache of the load.
+	lookups lookupStats
+
+	categories  map[string]bool
+	countries   map[string]bool
+	currencies  map[string]bool
+	first, last time.Time
+}
+
+// lookupSummary is how the rows of a load found the value of a lookup table:
+// how many rows did, how many found it in the lookup cache, how many values
+// were looked up in or inserted into the database instead, and the share of
+// the rows that the cache saved from the database.
+type lookupSummary struct {
+	Rows        int     `json:"rows"`
+	CacheHits   int     `json:"cache_hits"`
+	CacheMisses int     `json:"cache_misses"`
+	DedupRate   float64 `json:"dedup_rate"`
+}
+
+func newLoadReport() *loadReport {
+	return &loadReport{
+		categories: make(map[string]bool),
+		countries:  make(map[string]bool),
+		currencies: make(map[string]bool),
+	}
+}
+
+// add records that k was loaded. A nil report records nothing.
+func (r *loadReport) add(k Kickstart) {
+	if r == nil {
+		return
+	}
+	r.RowsLoaded++
+	// A category is a different one under each main category.
+	r.categories[k.MainCategory.Name+"\x00"+k.Category.Name] = true
+	r.countries[k.Area.Country] = true
+	r.currencies[k.Currency.Type] = true
+	if launched := k.Date.Launched; r.first.IsZero() || launched.Before(r.first) {
+		r.first = launched
+	}
+	if launched := k.Date.Launched; launched.After(r.last) {
+		r.last = launched
+	}
+}
+
+// finish fills in the totals of the report once the load is done. skipped
+// counts the rows the filter dropped, it may be nil.
+func (r *loadReport) finish(errs *rowErrors, skipped *skipCounts, duration time.Duration) {
+	r.RowsRead = errs.rows
+	r.RowsSkipped = len(errs.errs)
+	if skipped != nil {
+		r.RowsFiltered = skipped.Filtered
+		r.RowsAlreadyLoaded = skipped.AlreadyLoaded
+		r.RowsDuplicate = skipped.Duplicates
+	}
+	r.Categories = len(r.categories)
+	r.Countries = len(r.countries)
+	r.Currencies = len(r.currencies)
+	if r.RowsLoaded != 0 {
+		r.FirstLaunched = r.first.Format(launchedLayout)
+		r.LastLaunched = r.last.Format(launchedLayout)
+	}
+	r.Seconds = duration.Seconds()
+	if r.Seconds > 0 {
+		r.RowsPerSecond = float64(r.RowsLoaded) / r.Seconds
+	}
+	r.ExtractSeconds = r.times.Extract.Seconds()
+	r.TransformSeconds = r.times.Transform.Seconds()
+	r.LoadSeconds = r.times.Load.Seconds()
+	if r.lookups != nil {
+		r.Lookups = make(map[string]lookupSummary)
+		for table, c := range r.lookups {
+			s := lookupSummary{Rows: int(c.rows.Load()), CacheHits: int(c.hits.Load()), CacheMisses: int(c.misses.Load())}
+			if s.Rows != 0 && s.CacheMisses <= s.Rows {
+				s.DedupRate = 1 - float64(s.CacheMisses)/float64(s.Rows)
+			}
+			r.Lookups[table] = s
+		}
+	}
+}
+
+// write logs the report, or writes it to out as a JSON object if format is
+// json.
+func (r *loadReport) write(format string, out io.Writer) error {
+	if format == "json" {
+		return json.NewEncoder(out).Encode(r)
+	}
+	slog.Info("Load summary",
+		"rows_read", r.RowsRead,
+		"rows_loaded", r.RowsLoaded,
+		"rows_skipped", r.RowsSkipped,
+		"rows_filtered", r.RowsFiltered,
+		"rows_already_loaded", r.RowsAlreadyLoaded,
+		"rows_duplicate", r.RowsDuplicate,
+		"categories", r.Categories,
+		"countries", r.Countries,
+		"currencies", r.Currencies,
+		"first_launched", r.FirstLaunched,
+		"last_launched", r.LastLaunched,
+		"duration", time.Duration(r.Seconds*float64(time.Second)).Round(time.Millisecond),
+		"rows_per_second", int(r.RowsPerSecond),
+	)
+	for _, lt := range lookupTables {
+		s, ok := r.Lookups[lt.table]
+		if !ok {
+			continue
+		}
+		slog.Info("Lookup cache",
+			"table", lt.table,
+			"rows", s.Rows,
+			"cache_hits", s.CacheHits,
+			"cache_misses", s.CacheMisses,
+			"dedup_rate", fmt.Sprintf("%.2f%%", s.DedupRate*100),
+		)
+	}
+	return nil
+}
+
+// reportingLoader loads with Loader and records the kickstarts it loaded in
+// report.
+type reportingLoader struct {
+	Loader
+	report *loadReport
+}
+
+func (l reportingLoader) Load(ctx context.Context, kk []Kickstart) error {
+	if err := l.Loader.Load(ctx, kk); err != nil {
+		return err
+	}
+	for _, k := range kk {
+		l.report.add(k)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// retryBackoff is the wait before the first retry. It doubles with each one
+// after that.
+const retryBackoff = 100 * time.Millisecond
+
+// isRetryable reports whether err is a transient MySQL error that is worth
+// retrying, a deadlock (1213) or a lock wait timeout (1205).
+func isRetryable(err error) bool {
+	var merr *mysql.MySQLError
+	if !errors.As(err, &merr) {
+		return false
+	}
+	return merr.Number == 1213 || merr.Number == 1205
+}
+
+// withRetries calls fn and, as long as it fails with a retryable error, calls
+// it again up to maxRetries times with exponential backoff. Other errors are
+// returned immediately.
+//
+// A deadlock rolls back the whole transaction in MySQL so fn must run a
+// transaction of its own from the start and not a single statement of it.
+func withRetries(ctx context.Context, maxRetries int, fn func() error) error {
+	backoff := retryBackoff
+	for retry := 0; ; retry++ {
+		err := fn()
+		if err == nil || retry == maxRetries || !isRetryable(err) {
+			return err
+		}
+		slog.Warn("Retrying after transient error", "error", err, "retry", retry+1, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
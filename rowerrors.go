@@ -0,0 +1,80 @@
+package etl
+
+import (
+	"fmt"
+	"io"
+)
+
+// rowError describes why a field of a row of the input could not be parsed.
+// Line is the line of the input the field starts on and Field is its index in
+// the row. File is the name of the file of an input directory the row is in.
+type rowError struct {
+	File   string
+	Line   int
+	Field  int
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *rowError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s: line %d: parsing %s %q: %v", e.File, e.Line, e.Column, e.Value, e.Err)
+	}
+	return fmt.Sprintf("line %d: parsing %s %q: %v", e.Line, e.Column, e.Value, e.Err)
+}
+
+// maxReportedRowErrors is the most row errors that printReport lists one by
+// one.
+const maxReportedRowErrors = 20
+
+// rowErrors counts the rows read from the input and, if skip is true,
+// collects the rows that could not be parsed so that they are reported at the
+// end instead of stopping the run.
+type rowErrors struct {
+	skip bool
+	rows int
+	errs []*rowError
+	// warns, if not nil, gets a warning for each skipped row so that
+	// --fail-on-warnings catches them.
+	warns *warnings
+}
+
+// add records err if it is a *rowError and rows with errors are skipped.
+// Otherwise err is returned to stop the run.
+func (e *rowErrors) add(err error) error {
+	rerr, ok := err.(*rowError)
+	if !ok || !e.skip {
+		return err
+	}
+	e.errs = append(e.errs, rerr)
+	if e.warns != nil {
+		e.warns.warn("skipped row", "skipped row: %v", rerr)
+	}
+	return nil
+}
+
+// addFile adds the rows counted and the errors collected by o while reading the
+// file named file to e.
+func (e *rowErrors) addFile(file string, o *rowErrors) {
+	e.rows += o.rows
+	for _, rerr := range o.errs {
+		rerr.File = file
+		e.errs = append(e.errs, rerr)
+	}
+}
+
+// printReport writes the rows that were skipped because of errors to out.
+func (e *rowErrors) printReport(out io.Writer) {
+	if len(e.errs) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "%d of %d rows skipped because of errors:\n", len(e.errs), e.rows)
+	for i, err := range e.errs {
+		if i == maxReportedRowErrors {
+			fmt.Fprintf(out, "  ... and %d more\n", len(e.errs)-i)
+			break
+		}
+		fmt.Fprintf(out, "  %v\n", err)
+	}
+}
@@ -0,0 +1,301 @@
+package etl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// table describes one table of the star schema. The schema is defined once in
+// schemaTables and both the DDL and the exported schema metadata are generated
+// from it.
+type table struct {
+	Name        string       `json:"name"`
+	Columns     []column     `json:"columns"`
+	ForeignKeys []foreignKey `json:"foreign_keys,omitempty"`
+	// UniqueKeys are the sets of columns that are unique together.
+	UniqueKeys [][]string `json:"unique_keys,omitempty"`
+}
+
+type column struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	PrimaryKey    bool   `json:"primary_key,omitempty"`
+	AutoIncrement bool   `json:"auto_increment,omitempty"`
+	Unique        bool   `json:"unique,omitempty"`
+}
+
+type foreignKey struct {
+	Column    string `json:"column"`
+	RefTable  string `json:"ref_table"`
+	RefColumn string `json:"ref_column"`
+}
+
+func idColumn() column {
+	return column{Name: "id", Type: "INT", PrimaryKey: true, AutoIncrement: true}
+}
+
+// schemaTables lists the tables of the star schema in the order they must be
+// created, dimensions first and the kickstarts fact table last.
+var schemaTables = []table{
+	{
+		Name: "products",
+		Columns: []column{
+			idColumn(),
+			{Name: "kickstarter_id", Type: "INT", Unique: true},
+			{Name: "name", Type: "VARCHAR(255)"},
+		},
+	},
+	{
+		Name: "main_categories",
+		Columns: []column{
+			idColumn(),
+			{Name: "name", Type: "VARCHAR(255)", Unique: true},
+		},
+	},
+	{
+		Name: "categories",
+		Columns: []column{
+			idColumn(),
+			{Name: "name", Type: "VARCHAR(255)"},
+			{Name: "main_category_id", Type: "INT"},
+		},
+		ForeignKeys: []foreignKey{
+			{Column: "main_category_id", RefTable: "main_categories", RefColumn: "id"},
+		},
+		UniqueKeys: [][]string{{"name", "main_category_id"}},
+	},
+	{
+		Name: "currencies",
+		Columns: []column{
+			idColumn(),
+			{Name: "type", Type: "VARCHAR(255)", Unique: true},
+		},
+	},
+	{
+		Name: "dates",
+		Columns: []column{
+			idColumn(),
+			{Name: "deadline", Type: "DATE"},
+			{Name: "launched", Type: "DATETIME"},
+		},
+	},
+	{
+		Name: "states",
+		Columns: []column{
+			idColumn(),
+			{Name: "state", Type: "VARCHAR(255)", Unique: true},
+		},
+	},
+	{
+		Name: "areas",
+		Columns: []column{
+			idColumn(),
+			{Name: "country", Type: "VARCHAR(255)", Unique: true},
+		},
+	},
+	{
+		Name: "kickstarts",
+		Columns: []column{
+			idColumn(),
+			{Name: "backers", Type: "INT"},
+			{Name: "goal", Type: "NUMERIC(12,2)"},
+			{Name: "goal_usd_real", Type: "NUMERIC(12,2)"},
+			{Name: "pledged", Type: "NUMERIC(12,2)"},
+			{Name: "pledged_usd", Type: "NUMERIC(12,2)"},
+			{Name: "pledged_usd_real", Type: "NUMERIC(12,2)"},
+			{Name: "duration_days", Type: "INT"},
+			{Name: "funding_pct", Type: "NUMERIC(10,2)"},
+			{Name: "goal_reporting", Type: "NUMERIC(12,2)"},
+			{Name: "pledged_reporting", Type: "NUMERIC(12,2)"},
+			{Name: "row_hash", Type: "CHAR(64)"},
+			{Name: "product_id", Type: "INT"},
+			{Name: "main_category_id", Type: "INT"},
+			{Name: "category_id", Type: "INT"},
+			{Name: "currency_id", Type: "INT"},
+			{Name: "date_id", Type: "INT"},
+			{Name: "state_id", Type: "INT"},
+			{Name: "area_id", Type: "INT"},
+		},
+		ForeignKeys: []foreignKey{
+			{Column: "product_id", RefTable: "products", RefColumn: "id"},
+			{Column: "main_category_id", RefTable: "main_categories", RefColumn: "id"},
+			{Column: "category_id", RefTable: "categories", RefColumn: "id"},
+			{Column: "currency_id", RefTable: "currencies", RefColumn: "id"},
+			{Column: "date_id", RefTable: "dates", RefColumn: "id"},
+			{Column: "state_id", RefTable: "states", RefColumn: "id"},
+			{Column: "area_id", RefTable: "areas", RefColumn: "id"},
+		},
+	},
+}
+
+// createStatement returns the CREATE TABLE statement for t in dialect d.
+func (t table) createStatement(d dialect) string {
+	var defs []string
+	for _, c := range t.Columns {
+		defs = append(defs, d.columnDef(c))
+	}
+	for _, cols := range t.UniqueKeys {
+		defs = append(defs, fmt.Sprintf("UNIQUE (%s)", strings.Join(cols, ", ")))
+	}
+	if d.kind == mysqlDialect {
+		// MySQL has no CREATE INDEX IF NOT EXISTS so the indexes are created
+		// with the table instead.
+		for _, fk := range t.ForeignKeys {
+			defs = append(defs, fmt.Sprintf("INDEX %s (%s)", t.indexName(d, fk.Column), fk.Column))
+		}
+	}
+	for _, fk := range t.ForeignKeys {
+		defs = append(defs, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", fk.Column, d.table(fk.RefTable), fk.RefColumn))
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", d.table(t.Name), strings.Join(defs, ",\n\t"))
+}
+
+// indexStatements returns the statements that create an index on each foreign
+// key column of t if it does not exist yet. They are empty for MySQL where the
+// indexes are part of the CREATE TABLE statement.
+func (t table) indexStatements(d dialect) []string {
+	if d.kind == mysqlDialect {
+		return nil
+	}
+	var stmts []string
+	for _, fk := range t.ForeignKeys {
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", t.indexName(d, fk.Column), d.table(t.Name), fk.Column))
+	}
+	return stmts
+}
+
+func (t table) indexName(d dialect, column string) string {
+	return d.table(t.Name) + "_" + column + "_idx"
+}
+
+// withETLTables returns tables followed by the tables the ETL keeps its own
+// records in.
+func withETLTables(tables []table) []table {
+	all := make([]table, 0, len(tables)+3)
+	all = append(all, tables...)
+	return append(all, loadMetadataTable, checkpointTable, backfillTable)
+}
+
+// writeDDL writes the statements createTables runs to create tables in
+// dialect d to w, without running them.
+func writeDDL(w io.Writer, d dialect, tables []table) error {
+	var b strings.Builder
+	for _, t := range withETLTables(tables) {
+		fmt.Fprintf(&b, "%s;\n\n", t.createStatement(d))
+		for _, stmt := range t.indexStatements(d) {
+			fmt.Fprintf(&b, "%s;\n", stmt)
+		}
+		if len(t.indexStatements(d)) != 0 {
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeDDLFile writes the DDL of tables to the file at path, or to stdout if
+// path is -.
+func writeDDLFile(path string, d dialect, tables []table) error {
+	if path == stdinInput {
+		return writeDDL(os.Stdout, d, tables)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeDDL(f, d, tables); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// defaultLoadOrder returns the names of schemaTables in the order they are
+// defined which respects their foreign key dependencies.
+func defaultLoadOrder() []string {
+	var order []string
+	for _, t := range schemaTables {
+		order = append(order, t.Name)
+	}
+	return order
+}
+
+// parseLoadOrder parses a comma separated list of table names. Every table of
+// the schema must appear exactly once and after all the tables it references
+// through foreign keys.
+func parseLoadOrder(s string) ([]string, error) {
+	tables := make(map[string]table)
+	for _, t := range schemaTables {
+		tables[t.Name] = t
+	}
+	var order []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		t, ok := tables[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown table %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("table %s appears more than once", name)
+		}
+		for _, fk := range t.ForeignKeys {
+			if !seen[fk.RefTable] {
+				return nil, fmt.Errorf("table %s must come after %s which it references", name, fk.RefTable)
+			}
+		}
+		seen[name] = true
+		order = append(order, name)
+	}
+	if len(order) != len(schemaTables) {
+		for _, t := range schemaTables {
+			if !seen[t.Name] {
+				return nil, fmt.Errorf("missing table %s", t.Name)
+			}
+		}
+	}
+	return order, nil
+}
+
+// exportSchema writes a description of the schema to w in the given format
+// which can be "json" or "dot" (Graphviz). It does not need a database.
+func exportSchema(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(schemaTables)
+	case "dot":
+		return writeSchemaDot(w)
+	default:
+		return fmt.Errorf("unknown schema export format %q (must be json or dot)", format)
+	}
+}
+
+func writeSchemaDot(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph schema {\n")
+	b.WriteString("\tnode [shape=record];\n")
+	for _, t := range schemaTables {
+		var cols []string
+		for _, c := range t.Columns {
+			col := c.Name + " " + c.Type
+			if c.PrimaryKey {
+				col += " PK"
+			}
+			cols = append(cols, col+`\l`)
+		}
+		fmt.Fprintf(&b, "\t%s [label=\"{%s|%s}\"];\n", t.Name, t.Name, strings.Join(cols, ""))
+	}
+	for _, t := range schemaTables {
+		for _, fk := range t.ForeignKeys {
+			fmt.Fprintf(&b, "\t%s -> %s [label=\"%s\"];\n", t.Name, fk.RefTable, fk.Column)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
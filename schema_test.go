@@ -0,0 +1,77 @@
+package etl
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeleteTablesSQLite(t *testing.T) {
+	ctx := context.Background()
+	for _, prefix := range []string{"", "etl_"} {
+		t.Run("prefix "+prefix, func(t *testing.T) {
+			_, db := testDBConfig(t, "")
+			d, err := dialect{kind: sqliteDialect}.withPrefix(prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The tables with another prefix must be left alone.
+			other, err := dialect{kind: sqliteDialect}.withPrefix("other_")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range []dialect{d, other} {
+				if err := createTables(ctx, db, d, schemaTables); err != nil {
+					t.Fatal(err)
+				}
+			}
+			count := func(d dialect) int {
+				t.Helper()
+				n, err := countDatabaseTables(ctx, db, d, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return n
+			}
+			want := len(withETLTables(schemaTables))
+			if n := count(d); n != want {
+				t.Fatalf("countDatabaseTables after createTables = %d, want %d", n, want)
+			}
+			if err := deleteTables(ctx, db, d); err != nil {
+				t.Fatal(err)
+			}
+			if n := count(d); n != 0 {
+				t.Errorf("countDatabaseTables after deleteTables = %d, want 0", n)
+			}
+			if n := count(other); n != want {
+				t.Errorf("countDatabaseTables of the other prefix = %d, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestTruncateTablesSQLite(t *testing.T) {
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(testRow(nil), testRow(map[string]string{"ID": "2"}))))
+	c.Truncate = true
+	c.Yes = true
+	for i := 0; i < 2; i++ {
+		if err := Run(context.Background(), c); err != nil {
+			t.Fatalf("run %d failed: %v", i+1, err)
+		}
+	}
+	tests := []struct {
+		query string
+		want  int
+	}{
+		// The ids of the second load start from 1 again.
+		{"SELECT max(id) FROM kickstarts", 2},
+		{"SELECT max(id) FROM products", 2},
+		{"SELECT count(*) FROM kickstarts", 2},
+		// Both loads are recorded.
+		{"SELECT count(*) FROM load_metadata", 2},
+	}
+	for _, tt := range tests {
+		if got := queryInt(t, db, tt.query); got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package etl
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync/atomic"
+)
+
+// ErrInterrupted is returned by Run when an interrupt stopped the load early.
+// The rows loaded before it are committed and a later run with Resume loads
+// the rest.
+var ErrInterrupted = errors.New("interrupted")
+
+// shutdown turns the first interrupt into a request to stop once the row that
+// is being loaded is done, so no work is thrown away. A second interrupt calls
+// cancel which rolls back the load.
+type shutdown struct {
+	requested int32
+	// done is closed when a stop is requested.
+	done chan struct{}
+}
+
+func handleInterrupts(cancel context.CancelFunc) *shutdown {
+	s := &shutdown{done: make(chan struct{})}
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		<-c
+		atomic.StoreInt32(&s.requested, 1)
+		close(s.done)
+		slog.Warn("Interrupted, stopping after the current row. Interrupt again to roll back and exit.")
+		<-c
+		slog.Warn("Interrupted again, rolling back")
+		// Let a third interrupt kill the program if rolling back hangs.
+		signal.Stop(c)
+		cancel()
+	}()
+	return s
+}
+
+// cancelOnStop cancels the context of cancel as soon as a stop is requested,
+// for the runs that write nothing worth finishing, or when ctx is done.
+func (s *shutdown) cancelOnStop(ctx context.Context, cancel context.CancelFunc) {
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// stopRequested reports whether the loaders should stop.
+func (s *shutdown) stopRequested() bool {
+	return s != nil && atomic.LoadInt32(&s.requested) == 1
+}
@@ -0,0 +1,426 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Extractor reads the rows of a dataset.
+type Extractor interface {
+	Extract(ctx context.Context, r io.Reader) ([]Data, error)
+}
+
+// Transformer turns extracted rows into kickstarts.
+type Transformer interface {
+	Transform(ctx context.Context, dd []Data) ([]Kickstart, error)
+}
+
+// Loader stores kickstarts.
+type Loader interface {
+	Load(ctx context.Context, kk []Kickstart) error
+}
+
+// stageTimes are how long each stage of a run took.
+type stageTimes struct {
+	Extract   time.Duration
+	Transform time.Duration
+	Load      time.Duration
+}
+
+// runStages extracts the data from r with e, transforms it with t and loads it
+// with l. It returns the number of kickstarts that were loaded and how long
+// each stage took. If r is an io.Closer it is closed once it is extracted.
+func runStages(ctx context.Context, r io.Reader, e Extractor, t Transformer, l Loader) (int, stageTimes, error) {
+	var times stageTimes
+	start := time.Now()
+	data, err := e.Extract(ctx, r)
+	if c, ok := r.(io.Closer); ok {
+		// With --limit the rest of the input is never read, and a remote
+		// input must stop downloading instead of waiting for the load.
+		c.Close()
+	}
+	if err != nil {
+		return 0, times, fmt.Errorf("extracting data: %v", err)
+	}
+	times.Extract = time.Since(start)
+
+	slog.Info("Transforming data")
+	start = time.Now()
+	kk, err := t.Transform(ctx, data)
+	if err != nil {
+		return 0, times, fmt.Errorf("transforming data: %v", err)
+	}
+	times.Transform = time.Since(start)
+
+	start = time.Now()
+	if err := l.Load(ctx, kk); err != nil {
+		return 0, times, err
+	}
+	times.Load = time.Since(start)
+	return len(kk), times, nil
+}
+
+// CSVExtractor extracts the raw kickstarter CSV.
+type CSVExtractor struct {
+	in    inputOptions
+	warns *warnings
+	errs  *rowErrors
+}
+
+func (e *CSVExtractor) Extract(ctx context.Context, r io.Reader) ([]Data, error) {
+	warns, errs := e.warns, e.errs
+	if warns == nil {
+		warns = newWarnings(false)
+	}
+	if errs == nil {
+		errs = &rowErrors{}
+	}
+	in := e.in
+	in.format = csvInput
+	return extractData(ctx, r, in, warns, errs)
+}
+
+// newExtractor returns the extractor of the input format of in.
+func newExtractor(in inputOptions, warns *warnings, errs *rowErrors) Extractor {
+	if in.format == jsonLinesInput {
+		return &JSONLinesExtractor{maxRows: in.maxRows, errs: errs}
+	}
+	if in.format == xlsxInput {
+		return &XLSXExtractor{maxRows: in.maxRows, errs: errs}
+	}
+	return &CSVExtractor{in: in, warns: warns, errs: errs}
+}
+
+// inputExtractor returns the extractor of the input format of in, or if files
+// are the files of an input directory an extractor of all of them that
+// extracts up to workers of them at once.
+func inputExtractor(in inputOptions, files []string, workers int, timeout time.Duration, warns *warnings, errs *rowErrors) Extractor {
+	if files == nil {
+		return newExtractor(in, warns, errs)
+	}
+	return &filesExtractor{files: files, workers: workers, timeout: timeout, in: in, warns: warns, errs: errs}
+}
+
+// filesExtractor extracts the rows of several input files, extracting up to
+// workers of them at once. The reader it is given is not used. The rows are
+// returned in the order of the files, whatever the order the files are
+// extracted in, so that the kickstarts are deduplicated and get their ids
+// exactly like when the files are extracted one after the other. The first
+// file that fails cancels the extraction of the others.
+type filesExtractor struct {
+	files   []string
+	workers int
+	timeout time.Duration
+	in      inputOptions
+	warns   *warnings
+	errs    *rowErrors
+}
+
+func (e *filesExtractor) Extract(ctx context.Context, _ io.Reader) ([]Data, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The warnings and errors of each file are collected apart, as they are
+	// not safe for concurrent use, and added up in the order of the files.
+	type result struct {
+		data  []Data
+		warns *warnings
+		errs  *rowErrors
+		err   error
+	}
+	results := make([]result, len(e.files))
+	sem := make(chan struct{}, e.workers)
+	var wg sync.WaitGroup
+	for i, path := range e.files {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+			res := &results[i]
+			res.warns = newWarnings(e.warns.immediate)
+			res.errs = &rowErrors{skip: e.errs.skip, warns: res.warns}
+			res.data, res.err = e.extract(ctx, path, res.warns, res.errs)
+			if res.err != nil {
+				cancel()
+			}
+		}(i, path)
+	}
+	wg.Wait()
+
+	var dd []Data
+	var firstErr error
+	for i, res := range results {
+		if res.warns != nil {
+			e.warns.add(res.warns)
+			e.errs.addFile(filepath.Base(e.files[i]), res.errs)
+		}
+		// The files that were canceled fail with the error of the context,
+		// which is not the reason the extraction stopped.
+		if res.err != nil && (firstErr == nil || errors.Is(firstErr, context.Canceled) && !errors.Is(res.err, context.Canceled)) {
+			firstErr = res.err
+		}
+		dd = append(dd, res.data...)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if e.in.maxRows > 0 && len(dd) > e.in.maxRows {
+		dd = dd[:e.in.maxRows]
+	}
+	return dd, nil
+}
+
+// extract extracts the rows of the file at path.
+func (e *filesExtractor) extract(ctx context.Context, path string, warns *warnings, errs *rowErrors) ([]Data, error) {
+	r, name, err := openInput(ctx, path, e.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	slog.Debug("Extracting file", "file", path, "input", name)
+	dd, err := newExtractor(e.in, warns, errs).Extract(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	return dd, nil
+}
+
+// KickstartTransformer normalizes the extracted rows into kickstarts. It can
+// also filter them, validate their states and rename duplicate products.
+type KickstartTransformer struct {
+	warns *warnings
+	// filter drops the kickstarts that are not loaded.
+	filter rowFilter
+	// allowedStates are the states accepted when states are validated. A nil
+	// map disables validation.
+	allowedStates map[string]bool
+	// strictStates makes an unknown state an error.
+	strictStates bool
+	// rates convert the goal and pledged to the reporting currency. Nil rates
+	// disable the conversion.
+	rates            exchangeRates
+	renameDuplicates bool
+	// dedupLast drops every occurrence of a repeated kickstarter_id but the
+	// last one. The filter drops all but the first one instead.
+	dedupLast bool
+}
+
+func (t *KickstartTransformer) Transform(ctx context.Context, dd []Data) ([]Kickstart, error) {
+	warns := t.warns
+	if warns == nil {
+		warns = newWarnings(false)
+	}
+	kk, err := transformData(ctx, dd, t.filter, warns)
+	if err != nil {
+		return nil, err
+	}
+	if t.dedupLast {
+		n := len(kk)
+		kk = keepLastDuplicates(kk, warns)
+		if t.filter.skipped != nil {
+			t.filter.skipped.Duplicates += n - len(kk)
+		}
+	}
+	if t.allowedStates != nil {
+		n, err := validateStates(kk, t.allowedStates, t.strictStates, warns)
+		if err != nil {
+			return nil, err
+		}
+		if n != 0 {
+			slog.Warn("Found rows with unknown state", "rows", n)
+		}
+	}
+	if t.rates != nil {
+		if n := convertCurrencies(kk, t.rates, warns); n != 0 {
+			slog.Warn("Found rows without exchange rate", "rows", n)
+		}
+	}
+	if t.renameDuplicates {
+		n := renameDuplicateProducts(kk)
+		slog.Info("Renamed duplicate products", "products", n)
+	}
+	return kk, nil
+}
+
+// SQLLoader loads kickstarts into the star schema of a SQL database, creating
+// its tables if they do not exist.
+type SQLLoader struct {
+	db        *sql.DB
+	dialect   dialect
+	tables    []table
+	order     []string
+	batchSize int
+	// dimensionBatchSize is the number of lookup values inserted by each
+	// statement of the first pass of a load, see loadData.
+	dimensionBatchSize int
+	// lookups bound the memory of the lookup cache.
+	lookups lookupOptions
+	workers int
+	// maxRetries is how many times a load that failed with a transient error
+	// is retried from the start.
+	maxRetries int
+	merge      bool
+	upsert     bool
+	bulk       bool
+	limit      *throttle
+	stop       *shutdown
+	// meta describes the load that is recorded in load_metadata. Nothing is
+	// recorded if it is nil.
+	meta *loadMetadata
+
+	// Progress is called with the progress of a load. If it is nil, the
+	// progress is logged at debug level with the rate and the time remaining.
+	Progress ProgressFunc
+}
+
+// NewSQLLoader returns a loader that inserts into the MySQL database db.
+func NewSQLLoader(db *sql.DB) *SQLLoader {
+	return &SQLLoader{
+		db:                 db,
+		dialect:            dialect{kind: mysqlDialect},
+		tables:             schemaTables,
+		order:              defaultLoadOrder(),
+		batchSize:          1000,
+		dimensionBatchSize: 1000,
+		maxRetries:         3,
+		limit:              newThrottle(0),
+	}
+}
+
+func (l *SQLLoader) Load(ctx context.Context, kk []Kickstart) error {
+	slog.Info("Creating tables")
+	if err := createTables(ctx, l.db, l.dialect, l.tables); err != nil {
+		return err
+	}
+
+	if l.merge {
+		slog.Info("Merging data")
+		progress := l.Progress
+		if progress == nil {
+			progress = logProgress("Merging data")
+		}
+		err := mergeData(ctx, l.db, l.dialect, kk, l.order, l.lookups, l.meta, l.limit, l.stop, progress)
+		if err != nil && err != ErrInterrupted {
+			return fmt.Errorf("merging data: %v", err)
+		}
+		return err
+	}
+	if l.upsert {
+		slog.Info("Upserting data")
+		progress := l.Progress
+		if progress == nil {
+			progress = logProgress("Upserting data")
+		}
+		err := l.withRetries(ctx, func(lookups lookupOptions) error {
+			return upsertData(ctx, l.db, l.dialect, kk, lookups, l.meta, l.limit, l.stop, progress)
+		})
+		if err != nil && err != ErrInterrupted {
+			return fmt.Errorf("upserting data: %v", err)
+		}
+		return err
+	}
+	slog.Info("Loading data")
+	progress := l.Progress
+	if progress == nil {
+		progress = logProgress("Loading data")
+	}
+	err := l.withRetries(ctx, func(lookups lookupOptions) error {
+		if l.workers > 1 {
+			return loadDataParallel(ctx, l.db, l.dialect, kk, l.order, l.batchSize, l.dimensionBatchSize, l.workers, lookups, l.meta, l.limit, l.stop, progress)
+		}
+		return loadData(ctx, l.db, l.dialect, kk, l.order, l.batchSize, l.dimensionBatchSize, lookups, l.bulk, l.meta, l.limit, l.stop, progress)
+	})
+	if err != nil && err != ErrInterrupted {
+		return fmt.Errorf("loading data: %v", err)
+	}
+	return err
+}
+
+// withRetries calls fn with the lookup options of l like withRetries. Each
+// attempt counts its lookups afresh and only the counts of the last one are
+// added to the stats of l, so that a retried load is counted once.
+func (l *SQLLoader) withRetries(ctx context.Context, fn func(lookups lookupOptions) error) error {
+	var attempt lookupStats
+	err := withRetries(ctx, l.maxRetries, func() error {
+		lookups := l.lookups
+		if lookups.stats != nil {
+			attempt = newLookupStats()
+			lookups.stats = attempt
+		}
+		return fn(lookups)
+	})
+	l.lookups.stats.addAll(attempt)
+	return err
+}
+
+// sampleLoader loads nothing. It prints the number of kickstarts and the first
+// samples of them as CSV instead.
+type sampleLoader struct {
+	w       io.Writer
+	samples int
+}
+
+func (l *sampleLoader) Load(ctx context.Context, kk []Kickstart) error {
+	fmt.Fprintf(l.w, "Dry run: %d records would be loaded\n", len(kk))
+	if len(kk) == 0 {
+		return nil
+	}
+	sink, err := newCSVSink(l.w, false)
+	if err != nil {
+		return err
+	}
+	for i, k := range kk {
+		if i == l.samples {
+			break
+		}
+		if err := sink.Write(k); err != nil {
+			return err
+		}
+	}
+	return sink.Flush()
+}
+
+// countLoader loads nothing. It prints the number of kickstarts and the number
+// of distinct values of each lookup table among them instead, which is what
+// the lookup tables would hold after loading them into empty tables.
+type countLoader struct {
+	w io.Writer
+}
+
+func (l *countLoader) Load(ctx context.Context, kk []Kickstart) error {
+	fmt.Fprintf(l.w, "rows: %d\n", len(kk))
+	// The ids the rows would get stand in for the ids of the rows that a
+	// natural key references.
+	ids := make(map[string]map[string]int64)
+	for _, lt := range lookupTables {
+		ids[lt.table] = make(map[string]int64)
+	}
+	for _, k := range kk {
+		rowIDs := make(map[string]int64)
+		for _, lt := range lookupTables {
+			key := lookupKey(lt.values(k, rowIDs))
+			id, ok := ids[lt.table][key]
+			if !ok {
+				id = int64(len(ids[lt.table]) + 1)
+				ids[lt.table][key] = id
+			}
+			rowIDs[lt.table] = id
+		}
+	}
+	for _, lt := range lookupTables {
+		fmt.Fprintf(l.w, "%s: %d\n", lt.table, len(ids[lt.table]))
+	}
+	return nil
+}
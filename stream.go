@@ -0,0 +1,151 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// eachKickstart reads the raw kickstarter CSV from r and calls fn with each
+// row that passes filter once it has been extracted and transformed, so rows
+// flow through the pipeline one at a time instead of being held in memory. It
+// stops at the first error returned by fn.
+func eachKickstart(ctx context.Context, r io.Reader, in inputOptions, filter rowFilter, warns *warnings, errs *rowErrors, fn func(k Kickstart) error) error {
+	if filter.skipped == nil {
+		filter.skipped = &skipCounts{}
+	}
+	var (
+		id    int64
+		fnErr error
+	)
+	err := eachRow(ctx, r, in, warns, errs, func(d Data) error {
+		id++
+		k := transformRow(id, d, warns)
+		if !filter.keep(k, warns) {
+			return nil
+		}
+		fnErr = fn(k)
+		return fnErr
+	})
+	filter.skipped.log(int(id) - filter.skipped.total())
+	if err != nil && err != fnErr {
+		return fmt.Errorf("extracting data: %v", err)
+	}
+	return err
+}
+
+// streamOptions configure streamData.
+type streamOptions struct {
+	dialect   dialect
+	order     []string
+	batchSize int
+	in        inputOptions
+	filter    rowFilter
+	limit     *throttle
+	stop      *shutdown
+	warns     *warnings
+	errs      *rowErrors
+	// allowedStates are the states accepted when states are validated. A
+	// nil map disables validation.
+	allowedStates map[string]bool
+	// strictStates makes an unknown state an error.
+	strictStates bool
+	// rates convert the goal and pledged to the reporting currency. Nil rates
+	// disable the conversion.
+	rates exchangeRates
+	// report records the loaded rows if it is not nil.
+	report *loadReport
+	// meta describes the load that is recorded in load_metadata. Nothing is
+	// recorded if it is nil.
+	meta *loadMetadata
+	// lookups bound the memory of the lookup cache.
+	lookups lookupOptions
+}
+
+// streamData extracts, transforms and loads the raw kickstarter CSV from r
+// one row at a time so that memory use stays flat no matter the size of the
+// input. Like loadData, the rows are loaded in a single transaction. It
+// returns the number of rows that were loaded.
+func streamData(ctx context.Context, db *sql.DB, r io.Reader, opts streamOptions) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cache, err := newLoadedLookupCache(ctx, tx, opts.dialect, opts.lookups)
+	if err != nil {
+		return 0, err
+	}
+	batch := newFactBatch(tx, opts.dialect, opts.batchSize)
+	var (
+		loaded int
+		last   Kickstart
+	)
+	err = eachKickstart(ctx, r, opts.in, opts.filter, opts.warns, opts.errs, func(k Kickstart) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.stop.stopRequested() {
+			return ErrInterrupted
+		}
+		if opts.allowedStates != nil && !validateState(&k, opts.allowedStates, opts.warns) && opts.strictStates {
+			return unknownStateError(k)
+		}
+		if opts.rates != nil {
+			convertCurrency(&k, opts.rates, opts.warns)
+		}
+		if loaded%progressInterval == 0 {
+			slog.Debug("Streaming data", "rows", loaded, "rows_per_second", opts.limit.rate())
+		}
+		opts.limit.wait()
+
+		ids, err := insertDimensions(ctx, tx, opts.dialect, k, opts.order, cache)
+		if err != nil {
+			return err
+		}
+		if err := batch.add(ctx, k, ids); err != nil {
+			return err
+		}
+		loaded++
+		last = k
+		opts.report.add(k)
+		return nil
+	})
+	if err == ErrInterrupted {
+		if err := batch.flush(ctx); err != nil {
+			return 0, err
+		}
+		if err := saveCheckpoint(ctx, tx, opts.dialect, last, loaded); err != nil {
+			return 0, fmt.Errorf("saving checkpoint: %v", err)
+		}
+		if err := recordLoad(ctx, tx, opts.dialect, opts.meta, loaded); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+		slog.Info("Stopped loading", "rows", loaded)
+		return loaded, ErrInterrupted
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := batch.flush(ctx); err != nil {
+		return 0, err
+	}
+	if err := saveCheckpoint(ctx, tx, opts.dialect, last, loaded); err != nil {
+		return 0, fmt.Errorf("saving checkpoint: %v", err)
+	}
+	if err := recordLoad(ctx, tx, opts.dialect, opts.meta, loaded); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	slog.Info("Loaded data", "rows", loaded)
+	cache.logCardinality()
+	return loaded, nil
+}
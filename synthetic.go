@@ -0,0 +1,126 @@
+package etl
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// syntheticSeed seeds the generator of the synthetic dataset so that every
+// run writes the same rows and benchmarks of the load are comparable.
+const syntheticSeed = 2018
+
+// syntheticCategories are the main categories of the synthetic dataset and
+// their categories.
+var syntheticCategories = []struct {
+	main       string
+	categories []string
+}{
+	{"Art", []string{"Art", "Painting", "Public Art", "Sculpture"}},
+	{"Design", []string{"Product Design", "Graphic Design", "Architecture"}},
+	{"Film & Video", []string{"Documentary", "Narrative Film", "Shorts", "Animation"}},
+	{"Games", []string{"Tabletop Games", "Video Games", "Playing Cards"}},
+	{"Music", []string{"Music", "Rock", "Indie Rock", "Jazz"}},
+	{"Publishing", []string{"Poetry", "Fiction", "Nonfiction", "Children's Books"}},
+	{"Technology", []string{"Hardware", "Apps", "Gadgets", "Software"}},
+}
+
+// syntheticCountries are the countries of the synthetic dataset with their
+// currency and the value of a unit of it in USD.
+var syntheticCountries = []struct {
+	country, currency string
+	usd               float64
+}{
+	{"US", "USD", 1},
+	{"GB", "GBP", 1.34},
+	{"CA", "CAD", 0.78},
+	{"AU", "AUD", 0.77},
+	{"DE", "EUR", 1.19},
+	{"FR", "EUR", 1.19},
+	{"SE", "SEK", 0.12},
+}
+
+// syntheticStates are the states of the synthetic dataset, as often as they
+// roughly are in the real one.
+var syntheticStates = []string{
+	"failed", "failed", "failed", "failed", "failed",
+	"successful", "successful", "successful", "successful",
+	"canceled",
+}
+
+// writeSyntheticData writes n rows of a synthetic kickstarter CSV to w. The
+// rows have the columns and ranges of values of the real dataset, about one in
+// a hundred have an undefined country and no usd pledged like some real ones
+// do, and they are the same every time for the same n.
+func writeSyntheticData(w io.Writer, n int) error {
+	rnd := rand.New(rand.NewSource(syntheticSeed))
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(append([]string{}, csvColumns...), optionalColumns...)); err != nil {
+		return err
+	}
+	start := time.Date(2009, 4, 21, 0, 0, 0, 0, time.UTC)
+	span := int64(time.Date(2017, 12, 31, 0, 0, 0, 0, time.UTC).Sub(start) / time.Second)
+	for i := 0; i < n; i++ {
+		cat := syntheticCategories[rnd.Intn(len(syntheticCategories))]
+		country := syntheticCountries[rnd.Intn(len(syntheticCountries))]
+		state := syntheticStates[rnd.Intn(len(syntheticStates))]
+		launched := start.Add(time.Duration(rnd.Int63n(span)) * time.Second)
+		deadline := launched.AddDate(0, 0, 2+rnd.Intn(59))
+		goal := float64(100 * (1 + rnd.Intn(500)))
+		pledged := float64(rnd.Intn(int(goal*2))) + float64(rnd.Intn(100))/100
+		backers := int(pledged / float64(10+rnd.Intn(90)))
+
+		countryName, usdPledged := country.country, formatFloat(pledged*country.usd)
+		if rnd.Intn(100) == 0 {
+			countryName, usdPledged, state = undefinedCountry, "", "undefined"
+		}
+		row := []string{
+			strconv.Itoa(1000000000 + i),
+			fmt.Sprintf("Synthetic %s project %d", cat.main, i+1),
+			cat.categories[rnd.Intn(len(cat.categories))],
+			cat.main,
+			country.currency,
+			deadline.Format(deadlineLayout),
+			formatFloat(goal),
+			launched.Format(launchedLayout),
+			formatFloat(pledged),
+			state,
+			strconv.Itoa(backers),
+			countryName,
+			usdPledged,
+			formatFloat(pledged * country.usd),
+			formatFloat(goal * country.usd),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeSyntheticFile writes n rows of the synthetic dataset to the file at
+// path, or to stdout if path is empty.
+func writeSyntheticFile(path string, n int) error {
+	if path == "" {
+		bw := bufio.NewWriter(os.Stdout)
+		if err := writeSyntheticData(bw, n); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeSyntheticData(f, n); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,126 @@
+package etl
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// benchRows is the number of rows of the synthetic dataset the benchmarks of
+// the stages run on.
+const benchRows = 10000
+
+// syntheticCSV returns n rows of the synthetic dataset.
+func syntheticCSV(b *testing.B, n int) []byte {
+	b.Helper()
+	var buf bytes.Buffer
+	if err := writeSyntheticData(&buf, n); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// syntheticKickstarts returns n rows of the synthetic dataset extracted and
+// transformed.
+func syntheticKickstarts(b *testing.B, n int) []Kickstart {
+	b.Helper()
+	warns := newWarnings(false)
+	dd, err := extractData(context.Background(), bytes.NewReader(syntheticCSV(b, n)), inputOptions{}, warns, &rowErrors{warns: warns})
+	if err != nil {
+		b.Fatal(err)
+	}
+	kk, err := transformData(context.Background(), dd, rowFilter{}, warns)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return kk
+}
+
+// reportRowsPerSecond reports the rows per second of the b.N runs of rows rows
+// each.
+func reportRowsPerSecond(b *testing.B, rows int) {
+	b.ReportMetric(float64(rows*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+func TestSyntheticData(t *testing.T) {
+	var a, b bytes.Buffer
+	if err := writeSyntheticData(&a, 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSyntheticData(&b, 200); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("the synthetic dataset differs between two runs")
+	}
+	warns := newWarnings(false)
+	dd, err := extractData(context.Background(), &a, inputOptions{}, warns, &rowErrors{warns: warns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dd) != 200 {
+		t.Errorf("extracted %d synthetic rows, want 200", len(dd))
+	}
+}
+
+func BenchmarkExtract(b *testing.B) {
+	data := syntheticCSV(b, benchRows)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		warns := newWarnings(false)
+		if _, err := extractData(context.Background(), bytes.NewReader(data), inputOptions{}, warns, &rowErrors{warns: warns}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportRowsPerSecond(b, benchRows)
+}
+
+func BenchmarkTransform(b *testing.B) {
+	warns := newWarnings(false)
+	dd, err := extractData(context.Background(), bytes.NewReader(syntheticCSV(b, benchRows)), inputOptions{}, warns, &rowErrors{warns: warns})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := transformData(context.Background(), dd, rowFilter{seen: make(map[int64]bool)}, newWarnings(false)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportRowsPerSecond(b, benchRows)
+}
+
+// BenchmarkLoad loads the synthetic dataset into a new SQLite database with
+// fact batches of several sizes.
+func BenchmarkLoad(b *testing.B) {
+	ctx := context.Background()
+	d := dialect{kind: sqliteDialect}
+	kk := syntheticKickstarts(b, benchRows)
+	for _, batchSize := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				db, err := sql.Open("sqlite", filepath.Join(b.TempDir(), "kickstarter.db"))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := createTables(ctx, db, d, schemaTables); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+				err = loadData(ctx, db, d, kk, defaultLoadOrder(), batchSize, 1000, lookupOptions{}, false, nil, newThrottle(0), nil, func(int, int) {})
+				b.StopTimer()
+				db.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+			reportRowsPerSecond(b, benchRows)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package etl
+
+import "time"
+
+// throttle limits how many rows per second are loaded so that a load does not
+// saturate a shared database.
+type throttle struct {
+	rps   float64
+	start time.Time
+	n     int
+}
+
+// newThrottle returns a throttle that allows at most rps rows per second. A
+// zero rps means unlimited.
+func newThrottle(rps float64) *throttle {
+	return &throttle{rps: rps}
+}
+
+// wait blocks until the next row may be loaded. Rows are paced against the
+// time the first row was loaded so that short stalls are caught up on instead
+// of lowering the overall rate.
+func (t *throttle) wait() {
+	if t.n == 0 {
+		t.start = time.Now()
+	}
+	t.n++
+	if t.rps <= 0 {
+		return
+	}
+	due := t.start.Add(time.Duration(float64(t.n-1) / t.rps * float64(time.Second)))
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// rate returns the rows per second loaded so far.
+func (t *throttle) rate() float64 {
+	elapsed := time.Since(t.start).Seconds()
+	if t.n == 0 || elapsed == 0 {
+		return 0
+	}
+	return float64(t.n) / elapsed
+}
@@ -0,0 +1,34 @@
+package etl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	const rps, rows = 200, 41
+	l := newThrottle(rps)
+	start := time.Now()
+	for i := 0; i < rows; i++ {
+		l.wait()
+	}
+	// The first row is not delayed so the rest take (rows-1)/rps.
+	elapsed := time.Since(start)
+	if want := (rows - 1) * time.Second / rps; elapsed < want || elapsed > 2*want {
+		t.Errorf("loading %d rows at %d rows per second took %v, want about %v", rows, rps, elapsed, want)
+	}
+	if r := l.rate(); r > rps*1.1 {
+		t.Errorf("rate is %.0f rows per second, want at most about %d", r, rps)
+	}
+}
+
+func TestThrottleUnlimited(t *testing.T) {
+	l := newThrottle(0)
+	start := time.Now()
+	for i := 0; i < 100000; i++ {
+		l.wait()
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("unlimited throttle took %v for 100000 rows", elapsed)
+	}
+}
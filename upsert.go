@@ -0,0 +1,147 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// upsertData loads kk like loadData, except that a kickstart whose
+// kickstarter_id is already loaded updates the existing rows in place instead
+// of failing on the unique key of products.kickstarter_id. Unlike mergeData it
+// does not compare row hashes, every row is written.
+//
+// The product is upserted with INSERT ... ON DUPLICATE KEY UPDATE (ON CONFLICT
+// with PostgreSQL and SQLite). The date row belongs to its fact row alone so it
+// is updated in place. Lookup rows are shared, so the fact row is pointed at
+// the rows of its new values and the lookup rows that no fact row references
+// any more are deleted at the end, so that they do not linger as stale values.
+// Like loadData, the upsert happens in a single transaction.
+func upsertData(ctx context.Context, db *sql.DB, d dialect, kk []Kickstart, lookups lookupOptions, meta *loadMetadata, limit *throttle, stop *shutdown, progress ProgressFunc) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cache, err := newLoadedLookupCache(ctx, tx, d, lookups)
+	if err != nil {
+		return err
+	}
+	var inserted, updated int
+	for i, k := range kk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stop.stopRequested() {
+			if _, err := deleteUnusedLookups(ctx, tx, d); err != nil {
+				return err
+			}
+			if err := recordLoad(ctx, tx, d, meta, i); err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			slog.Info("Stopped upserting", "rows", i, "total", len(kk))
+			return ErrInterrupted
+		}
+		if i%progressInterval == 0 {
+			progress(i, len(kk))
+		}
+		limit.wait()
+
+		wasUpdated, err := upsertKickstart(ctx, tx, d, k, cache)
+		if err != nil {
+			return err
+		}
+		if wasUpdated {
+			updated++
+		} else {
+			inserted++
+		}
+	}
+	deleted, err := deleteUnusedLookups(ctx, tx, d)
+	if err != nil {
+		return err
+	}
+	if err := recordLoad(ctx, tx, d, meta, len(kk)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	progress(len(kk), len(kk))
+	slog.Info("Upserted data", "rows", len(kk), "inserted", inserted, "updated", updated, "unused_lookup_values_deleted", deleted)
+	return nil
+}
+
+// upsertKickstart inserts the rows of k, or updates them if its kickstarter_id
+// is already loaded. It reports whether the rows were updated.
+func upsertKickstart(ctx context.Context, db execer, d dialect, k Kickstart, cache *lookupCache) (bool, error) {
+	productID, err := d.upsertID(ctx, db, "products", "kickstarter_id", []string{"name"}, []string{"kickstarter_id", "name"}, k.Product.KickstarterID, k.Product.Name)
+	if err != nil {
+		return false, fmt.Errorf("upserting into products: %w", err)
+	}
+	ids := map[string]int64{"products": productID}
+	for _, lt := range lookupTables {
+		if ids[lt.table], err = cache.getOrInsert(ctx, db, d, lt, lt.values(k, ids)...); err != nil {
+			return false, fmt.Errorf("inserting into %s: %w", lt.table, err)
+		}
+	}
+
+	var factID, dateID int64
+	query := fmt.Sprintf("SELECT id, date_id FROM %s WHERE product_id = ?", d.table("kickstarts"))
+	err = db.QueryRowContext(ctx, d.rebind(query), productID).Scan(&factID, &dateID)
+	if err == sql.ErrNoRows {
+		if ids["dates"], err = insertRow(ctx, db, d, "dates", k, ids); err != nil {
+			return false, fmt.Errorf("inserting into dates: %w", err)
+		}
+		if _, err := insertRow(ctx, db, d, "kickstarts", k, ids); err != nil {
+			return false, fmt.Errorf("inserting into kickstarts: %w", err)
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("looking up kickstarter_id %d: %w", k.Product.KickstarterID, err)
+	}
+
+	updateDate := fmt.Sprintf("UPDATE %s SET deadline = ?, launched = ? WHERE id = ?", d.table("dates"))
+	if _, err := db.ExecContext(ctx, d.rebind(updateDate), d.timeArg(k.Date.Deadline), d.timeArg(k.Date.Launched), dateID); err != nil {
+		return false, fmt.Errorf("updating date of kickstart %d: %w", factID, err)
+	}
+	ids["dates"] = dateID
+	var sets []string
+	for _, c := range kickstartColumns {
+		sets = append(sets, c+" = ?")
+	}
+	updateFact := fmt.Sprintf("UPDATE %s SET %s WHERE id = ?", d.table("kickstarts"), strings.Join(sets, ", "))
+	args := append(kickstartArgs(k, ids), factID)
+	if _, err := db.ExecContext(ctx, d.rebind(updateFact), args...); err != nil {
+		return false, fmt.Errorf("updating kickstart %d: %w", factID, err)
+	}
+	return true, nil
+}
+
+// deleteUnusedLookups deletes the rows of the lookup tables that no fact row
+// references and returns how many were deleted. The tables are emptied in
+// reverse order so that the rows that reference a row are deleted first.
+func deleteUnusedLookups(ctx context.Context, db execer, d dialect) (int, error) {
+	deleted := 0
+	for i := len(lookupTables) - 1; i >= 0; i-- {
+		lt := lookupTables[i]
+		query := fmt.Sprintf("DELETE FROM %s WHERE id NOT IN (SELECT %s FROM %s WHERE %s IS NOT NULL)", d.table(lt.table), lt.fkColumn, d.table("kickstarts"), lt.fkColumn)
+		res, err := db.ExecContext(ctx, query)
+		if err != nil {
+			return 0, fmt.Errorf("deleting unused rows of %s: %w", lt.table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += int(n)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,59 @@
+package etl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KnownStates are the values the state column of the kickstarter dataset is
+// expected to have.
+const KnownStates = "successful,failed,canceled,live,suspended,undefined"
+
+// parseStateSet parses a comma separated list of states.
+func parseStateSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, state := range strings.Split(s, ",") {
+		if state = normalizeState(state); state != "" {
+			set[state] = true
+		}
+	}
+	return set
+}
+
+func normalizeState(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// validateStates normalizes the state of every kickstart in kk to lower case
+// without surrounding whitespace and returns the number of kickstarts whose
+// state is not in allowed. Unknown states are left in place so they can be
+// inspected; they usually mean that the columns of a row are misaligned. If
+// strict is true, the first unknown state is returned as an error instead.
+func validateStates(kk []Kickstart, allowed map[string]bool, strict bool, warns *warnings) (int, error) {
+	unknown := 0
+	for i := range kk {
+		if !validateState(&kk[i], allowed, warns) {
+			if strict {
+				return unknown, unknownStateError(kk[i])
+			}
+			unknown++
+		}
+	}
+	return unknown, nil
+}
+
+func unknownStateError(k Kickstart) error {
+	return fmt.Errorf("kickstarter_id %d has unknown state %q", k.Product.KickstarterID, k.State.State)
+}
+
+// validateState normalizes the state of k and reports whether it is in
+// allowed.
+func validateState(k *Kickstart, allowed map[string]bool, warns *warnings) bool {
+	state := normalizeState(k.State.State)
+	k.State.State = state
+	if !allowed[state] {
+		warns.warn("unknown state", "kickstarter_id %d has state %q", k.Product.KickstarterID, state)
+		return false
+	}
+	return true
+}
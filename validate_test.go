@@ -0,0 +1,52 @@
+package etl
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateStates(t *testing.T) {
+	kk := testKickstarts(t,
+		testRow(map[string]string{"ID": "1", "state": " Successful "}),
+		testRow(map[string]string{"ID": "2", "state": "garbage"}),
+	)
+	warns := newWarnings(false)
+	unknown, err := validateStates(kk, parseStateSet(KnownStates), false, warns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unknown != 1 {
+		t.Errorf("validateStates found %d unknown states, want 1", unknown)
+	}
+	if kk[0].State.State != "successful" {
+		t.Errorf("state is %q, want it normalized to successful", kk[0].State.State)
+	}
+	if kk[1].State.State != "garbage" {
+		t.Errorf("unknown state is %q, want it left as garbage", kk[1].State.State)
+	}
+	if n := warns.counts["unknown state"]; n != 1 {
+		t.Errorf("%d unknown state warnings, want 1", n)
+	}
+
+	_, err = validateStates(kk, parseStateSet(KnownStates), true, newWarnings(false))
+	if err == nil || !strings.Contains(err.Error(), `kickstarter_id 2 has unknown state "garbage"`) {
+		t.Errorf("strict validateStates returned %v, want an unknown state error", err)
+	}
+}
+
+func TestRunStrictStates(t *testing.T) {
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(
+		testRow(map[string]string{"ID": "1"}),
+		testRow(map[string]string{"ID": "2", "state": "garbage"}),
+	)))
+	c.Strict = true
+	err := Run(context.Background(), c)
+	if err == nil || !strings.Contains(err.Error(), "unknown state") {
+		t.Fatalf("Run returned %v, want an unknown state error", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM kickstarts").Scan(&n); err == nil && n != 0 {
+		t.Errorf("strict load of a garbage state loaded %d kickstarts, want none", n)
+	}
+}
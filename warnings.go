@@ -0,0 +1,103 @@
+package etl
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// warningsPerSecond is the most warnings that are logged per second when
+// warnings are logged as they occur. The rest are only counted so that a file
+// full of bad rows does not drown the output.
+const warningsPerSecond = 10
+
+// warnings collects the data quality warnings of a run, such as skipped rows
+// or unknown values, and counts them by kind.
+type warnings struct {
+	immediate bool
+	counts    map[string]int
+	kinds     []string
+
+	window     time.Time
+	inWindow   int
+	suppressed int
+}
+
+// newWarnings returns a collector of warnings. If immediate is true, each
+// warning is also logged as it occurs.
+func newWarnings(immediate bool) *warnings {
+	return &warnings{
+		immediate: immediate,
+		counts:    make(map[string]int),
+	}
+}
+
+func (w *warnings) warn(kind, format string, args ...interface{}) {
+	if w.counts[kind] == 0 {
+		w.kinds = append(w.kinds, kind)
+	}
+	w.counts[kind]++
+	if !w.immediate {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(w.window) >= time.Second {
+		if w.suppressed != 0 {
+			slog.Warn("More warnings suppressed", "warnings", w.suppressed)
+		}
+		w.window = now
+		w.inWindow = 0
+		w.suppressed = 0
+	}
+	if w.inWindow >= warningsPerSecond {
+		w.suppressed++
+		return
+	}
+	w.inWindow++
+	slog.Warn(fmt.Sprintf(format, args...), "kind", kind)
+}
+
+// add adds the warnings counted by o to w.
+func (w *warnings) add(o *warnings) {
+	for _, kind := range o.kinds {
+		if w.counts[kind] == 0 {
+			w.kinds = append(w.kinds, kind)
+		}
+		w.counts[kind] += o.counts[kind]
+	}
+	w.suppressed += o.suppressed
+}
+
+// total returns the number of warnings of all kinds.
+func (w *warnings) total() int {
+	n := 0
+	for _, c := range w.counts {
+		n += c
+	}
+	return n
+}
+
+// err returns an error if any warning occurred.
+func (w *warnings) err() error {
+	if n := w.total(); n != 0 {
+		return fmt.Errorf("%d data quality warnings", n)
+	}
+	return nil
+}
+
+// printSummary writes the number of warnings of each kind to out.
+func (w *warnings) printSummary(out io.Writer) {
+	if w.immediate && w.suppressed != 0 {
+		slog.Warn("More warnings suppressed", "warnings", w.suppressed)
+		w.suppressed = 0
+	}
+	if len(w.kinds) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "Warnings (%d):\n", w.total())
+	for _, kind := range w.kinds {
+		fmt.Fprintf(out, "  %s: %d\n", kind, w.counts[kind])
+	}
+}
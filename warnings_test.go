@@ -0,0 +1,58 @@
+package etl
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFailOnWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []string
+		wantFail bool
+	}{
+		{"clean row", []string{testRow(nil)}, false},
+		{"empty usd pledged", []string{testRow(map[string]string{"usd pledged": ""})}, true},
+		{"garbage usd pledged", []string{testRow(nil), testRow(map[string]string{"ID": "2", "usd pledged": "1.2.3"})}, true},
+		{"garbage goal", []string{testRow(map[string]string{"goal": "abc"})}, true},
+		{"short row", []string{testRow(nil), "1,short"}, true},
+		{"unknown state", []string{testRow(map[string]string{"state": "garbage"})}, true},
+		{"non-positive duration", []string{testRow(map[string]string{"deadline": "2017-09-01"})}, true},
+		{"undefined country", []string{testRow(map[string]string{"country": `"N,0"""`})}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig(writeTestFile(t, "in.csv", testCSV(tt.rows...)), filepath.Join(t.TempDir(), "out.csv"))
+			c.SkipErrors = true
+			c.FailOnWarnings = true
+			err := Run(context.Background(), c)
+			if tt.wantFail && (err == nil || !strings.Contains(err.Error(), "data quality warnings")) {
+				t.Fatalf("Run returned %v, want a data quality error", err)
+			}
+			if !tt.wantFail && err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestWarningsCount(t *testing.T) {
+	w := newWarnings(false)
+	if err := w.err(); err != nil {
+		t.Fatalf("err() of no warnings = %v, want nil", err)
+	}
+	w.warn("unknown state", "state %q", "x")
+	w.warn("unknown state", "state %q", "y")
+	w.warn("skipped row", "row %d", 3)
+	if got, want := w.total(), 3; got != want {
+		t.Errorf("total() = %d, want %d", got, want)
+	}
+	if got, want := w.counts["unknown state"], 2; got != want {
+		t.Errorf(`counts["unknown state"] = %d, want %d`, got, want)
+	}
+	if err := w.err(); err == nil {
+		t.Error("err() of 3 warnings = nil, want an error")
+	}
+}
@@ -0,0 +1,224 @@
+package etl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// loadDataParallel loads kk like loadData but spreads the rows over workers
+// goroutines which each insert with a connection and transaction of their
+// own. If any worker fails the transactions of all of them are rolled back.
+// Otherwise they are committed one after the other once every worker is done,
+// which is not atomic: if a commit fails, the rows of the transactions
+// committed before it stay loaded and a run with --resume loads the rest.
+//
+// The lookup values are inserted first in a single-threaded pass of their own
+// and committed, so that the workers only read the lookup cache and never
+// insert the same value twice. They cannot wait for the commit of the workers
+// as the foreign keys of the fact rows would wait for them, so if the load
+// fails the lookup rows it inserted that no fact row references are deleted
+// instead. batchSize is the number of fact rows and dimensionBatchSize the
+// number of lookup values inserted by each statement.
+func loadDataParallel(ctx context.Context, db *sql.DB, d dialect, kk []Kickstart, order []string, batchSize, dimensionBatchSize, workers int, lookups lookupOptions, meta *loadMetadata, limit *throttle, stop *shutdown, progress ProgressFunc) (err error) {
+	since, err := maxLookupIDs(ctx, db, d)
+	if err != nil {
+		return fmt.Errorf("reading lookup ids: %v", err)
+	}
+	cache, err := preloadLookups(ctx, db, d, kk, dimensionBatchSize, lookups)
+	if err != nil {
+		return fmt.Errorf("inserting lookup values: %w", err)
+	}
+	// The deletion is not canceled with the load, which a canceled context
+	// may have failed.
+	cleanupCtx := context.WithoutCancel(ctx)
+	defer func() {
+		if err == nil || err == ErrInterrupted {
+			return
+		}
+		n, derr := deleteLookupsAfter(cleanupCtx, db, d, since)
+		if derr != nil {
+			slog.Warn("Could not delete the lookup values of the failed load", "error", derr)
+			return
+		}
+		slog.Info("Deleted lookup values of the failed load", "rows", n)
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		chunks   = make(chan []Kickstart)
+		txs      = make([]*sql.Tx, workers)
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		firstErr error
+	)
+	// fail stops the load at the first error. The errors of the other workers
+	// that follow are caused by the cancellation and are dropped.
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	// Every transaction is begun before any worker starts, so that a failure
+	// to begin one returns without workers left waiting for chunks.
+	for w := 0; w < workers; w++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		txs[w] = tx
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			if err := loadChunks(ctx, txs[w], d, chunks, order, batchSize, cache); err != nil {
+				fail(err)
+			}
+		}(w)
+	}
+
+	sent, stopped := 0, false
+dispatch:
+	for sent < len(kk) {
+		if stop.stopRequested() {
+			stopped = true
+			break
+		}
+		end := sent + batchSize
+		if end > len(kk) {
+			end = len(kk)
+		}
+		for range kk[sent:end] {
+			limit.wait()
+		}
+		select {
+		case chunks <- kk[sent:end]:
+		case <-ctx.Done():
+			break dispatch
+		}
+		sent = end
+		if sent%progressInterval < batchSize {
+			progress(sent, len(kk))
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	// The rows were committed by several transactions so the checkpoint and
+	// the load metadata can only be saved once all of them are.
+	if sent > 0 {
+		if err := saveCheckpoint(ctx, db, d, kk[sent-1], sent); err != nil {
+			return fmt.Errorf("saving checkpoint: %v", err)
+		}
+	}
+	if err := recordLoad(ctx, db, d, meta, sent); err != nil {
+		return err
+	}
+	if stopped {
+		slog.Info("Stopped loading", "rows", sent, "total", len(kk))
+		return ErrInterrupted
+	}
+	progress(len(kk), len(kk))
+	slog.Info("Loaded data", "rows", len(kk), "workers", workers)
+	cache.logCardinality()
+	return nil
+}
+
+// preloadLookups inserts the distinct lookup values of kk in a transaction of
+// its own, batchSize values per statement, and returns the cache of their ids.
+func preloadLookups(ctx context.Context, db *sql.DB, d dialect, kk []Kickstart, batchSize int, lookups lookupOptions) (*lookupCache, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cache, err := newLoadedLookupCache(ctx, tx, d, lookups)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.insertLookupValues(ctx, tx, d, kk, batchSize); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// maxLookupIDs returns the largest id of each lookup table by its name, zero
+// for an empty table.
+func maxLookupIDs(ctx context.Context, db execer, d dialect) (map[string]int64, error) {
+	ids := make(map[string]int64, len(lookupTables))
+	for _, lt := range lookupTables {
+		var id int64
+		if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM "+d.table(lt.table)).Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s: %v", lt.table, err)
+		}
+		ids[lt.table] = id
+	}
+	return ids, nil
+}
+
+// deleteLookupsAfter deletes the rows of the lookup tables whose id is above
+// that of their table in since and that no fact row references, and returns
+// how many were deleted. Like deleteUnusedLookups, the tables are emptied in
+// reverse order.
+func deleteLookupsAfter(ctx context.Context, db execer, d dialect, since map[string]int64) (int, error) {
+	deleted := 0
+	for i := len(lookupTables) - 1; i >= 0; i-- {
+		lt := lookupTables[i]
+		query := fmt.Sprintf("DELETE FROM %s WHERE id > ? AND id NOT IN (SELECT %s FROM %s WHERE %s IS NOT NULL)", d.table(lt.table), lt.fkColumn, d.table("kickstarts"), lt.fkColumn)
+		res, err := db.ExecContext(ctx, d.rebind(query), since[lt.table])
+		if err != nil {
+			return 0, fmt.Errorf("deleting rows of %s: %v", lt.table, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += int(n)
+	}
+	return deleted, nil
+}
+
+// loadChunks inserts the rows of each chunk received from chunks with tx. The
+// lookup values of the rows must already be in cache.
+func loadChunks(ctx context.Context, tx *sql.Tx, d dialect, chunks <-chan []Kickstart, order []string, batchSize int, cache *lookupCache) error {
+	batch := newFactBatch(tx, d, batchSize)
+	for chunk := range chunks {
+		for _, k := range chunk {
+			ids, err := insertDimensions(ctx, tx, d, k, order, cache)
+			if err != nil {
+				return err
+			}
+			if err := batch.add(ctx, k, ids); err != nil {
+				return err
+			}
+		}
+	}
+	return batch.flush(ctx)
+}
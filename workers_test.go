@@ -0,0 +1,30 @@
+package etl
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLoadDataParallelFailure(t *testing.T) {
+	ctx := context.Background()
+	d := dialect{kind: sqliteDialect}
+	c, db := testDBConfig(t, writeTestFile(t, "in.csv", testCSV(testRow(map[string]string{"ID": "1"}))))
+	if err := Run(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	want := dumpTables(t, db)
+
+	// The repeated kickstarter_id fails the insert of its product once the
+	// lookup values of both rows are committed.
+	kk := testKickstarts(t,
+		testRow(map[string]string{"ID": "2", "country": "GB", "category": "Poetry", "main_category": "Publishing", "currency": "GBP"}),
+		testRow(map[string]string{"ID": "2", "country": "GB", "category": "Poetry", "main_category": "Publishing", "currency": "GBP"}),
+	)
+	err := loadDataParallel(ctx, db, d, kk, defaultLoadOrder(), 1000, 1000, 1, lookupOptions{}, nil, newThrottle(0), nil, func(int, int) {})
+	if err == nil {
+		t.Fatal("loading a repeated kickstarter_id succeeded")
+	}
+	if got := dumpTables(t, db); got != want {
+		t.Errorf("the failed load left\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,168 @@
+package etl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// XLSXExtractor extracts rows from the first sheet of an Excel workbook whose
+// first row is the header of the kickstarter CSV.
+type XLSXExtractor struct {
+	// maxRows is the most data rows that are read. Zero means all of them.
+	maxRows int
+	errs    *rowErrors
+}
+
+func (e *XLSXExtractor) Extract(ctx context.Context, r io.Reader) ([]Data, error) {
+	errs := e.errs
+	if errs == nil {
+		errs = &rowErrors{}
+	}
+	return extractData(ctx, r, inputOptions{format: xlsxInput, maxRows: e.maxRows}, newWarnings(false), errs)
+}
+
+// xlsxNumberColumns are the columns of the kickstarter CSV that hold numbers.
+// Cells of them that are text may have thousands separators, e.g. 1,500.
+var xlsxNumberColumns = []string{
+	"ID",
+	"goal",
+	"pledged",
+	"backers",
+	"usd pledged",
+	"usd_pledged_real",
+	"usd_goal_real",
+}
+
+// eachXLSXRow is eachRow for an Excel workbook. The cells are read raw, rather
+// than formatted, and turned into fields of the kickstarter CSV so that each
+// row is parsed like one of the CSV. Blank rows are ignored.
+func eachXLSXRow(ctx context.Context, r io.Reader, maxRows int, errs *rowErrors, fn func(d Data) error) error {
+	f, err := excelize.OpenReader(r, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return fmt.Errorf("reading workbook: %v", err)
+	}
+	defer f.Close()
+	props, err := f.GetWorkbookProps()
+	if err != nil {
+		return fmt.Errorf("reading workbook: %v", err)
+	}
+	date1904 := props.Date1904 != nil && *props.Date1904
+
+	sheet := f.GetSheetName(0)
+	rows, err := f.Rows(sheet)
+	if err != nil {
+		return fmt.Errorf("reading sheet %s: %v", sheet, err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Error(); err != nil {
+			return fmt.Errorf("reading sheet %s: %v", sheet, err)
+		}
+		return fmt.Errorf("sheet %s is empty", sheet)
+	}
+	header, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("reading sheet %s: %v", sheet, err)
+	}
+	idx, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+	for line, n := 1, 0; maxRows <= 0 || n < maxRows; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !rows.Next() {
+			if err := rows.Error(); err != nil {
+				return fmt.Errorf("reading sheet %s: %v", sheet, err)
+			}
+			return nil
+		}
+		line++
+		row, err := rows.Columns()
+		if err != nil {
+			return fmt.Errorf("reading sheet %s: %v", sheet, err)
+		}
+		if blankRow(row) {
+			continue
+		}
+		n++
+		errs.rows++
+		validUTF8(row)
+		// Trailing empty cells are not stored in the sheet.
+		for len(row) < len(header) {
+			row = append(row, "")
+		}
+		normalizeXLSXRow(row, idx, date1904)
+		d, err := parseRow(row, idx)
+		if rerr, ok := err.(*rowError); ok {
+			rerr.Line = line
+		}
+		if err != nil {
+			if err := errs.add(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeXLSXRow turns the raw cells of row into fields as they appear in
+// the kickstarter CSV. Dates that Excel stores as serial numbers are formatted
+// with the layouts of the CSV and the thousands separators of numbers stored
+// as text are removed. Cells that are not numbers are left for parseRow to
+// report.
+func normalizeXLSXRow(row []string, idx columnIndex, date1904 bool) {
+	for _, c := range [...]struct {
+		name, layout string
+	}{
+		{"deadline", deadlineLayout},
+		{"launched", launchedLayout},
+	} {
+		i := idx[c.name]
+		serial, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+		if err != nil {
+			continue
+		}
+		t, err := excelize.ExcelDateToTime(serial, date1904)
+		if err != nil {
+			continue
+		}
+		row[i] = t.Format(c.layout)
+	}
+	for _, name := range xlsxNumberColumns {
+		i, ok := idx[name]
+		if !ok {
+			continue
+		}
+		v := strings.TrimSpace(row[i])
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			row[i] = v
+			continue
+		}
+		if n := strings.ReplaceAll(v, ",", ""); n != "" {
+			if _, err := strconv.ParseFloat(n, 64); err == nil {
+				row[i] = n
+			}
+		}
+	}
+}
+
+// blankRow reports whether every cell of row is empty.
+func blankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}